@@ -0,0 +1,144 @@
+// Package cli runs the commands of padron, which build, check and
+// serve the database, with the settings they share.  Each command is
+// also a program of its own, see Run.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"model"
+	"os"
+	"strings"
+
+	"github.com/coopernurse/gorp"
+)
+
+// Command is a command of padron.
+type Command struct {
+	Name      string
+	UsageLine string // arguments after the flags
+	Short     string // shown in the list of commands
+	Long      string
+
+	// Flag are the flags of the command, besides the shared ones
+	Flag flag.FlagSet
+
+	// Run runs the command with the arguments left after the flags
+	Run func(cmd *Command, args []string)
+}
+
+// Settings shared by the commands.  The flags of every command set
+// them, the environment gives their defaults.
+var (
+	// Db is the database the command works on, -db
+	Db = "padron.db"
+
+	// LogFile is a file the log is also appended to, -log
+	LogFile string
+)
+
+// prog is how the running program is called, for its usage
+var prog = "padron"
+
+func init() {
+	if db := os.Getenv("PADRON_DB"); db != "" {
+		Db = db
+	}
+	LogFile = os.Getenv("PADRON_LOG")
+}
+
+// Usage prints how to call c.
+func (c *Command) Usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] %s\n\n", prog, c.UsageLine)
+	if c.Long != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", strings.TrimSpace(c.Long))
+	}
+	c.Flag.PrintDefaults()
+}
+
+// Fail prints how to call c and exits.
+func (c *Command) Fail() {
+	c.Usage()
+	os.Exit(2)
+}
+
+// OpenDb opens Db, exiting if it can't.
+func OpenDb() *gorp.DbMap {
+	dbmap, err := model.OpenDb(Db)
+	if err != nil {
+		log.Fatalf(`E: Can't open %s: %s. Abort.`, Db, err)
+	}
+	return dbmap
+}
+
+// Run runs c with the arguments of the program, which is c alone.
+func Run(c *Command) {
+	prog = os.Args[0]
+	run(c, os.Args[1:])
+}
+
+// addFlags adds the shared flags to those of c.
+func addFlags(c *Command) {
+	c.Flag.Init(c.Name, flag.ExitOnError)
+	c.Flag.Usage = c.Usage
+	c.Flag.StringVar(&Db, "db", Db, "database, $PADRON_DB if set")
+	c.Flag.StringVar(&LogFile, "log", LogFile,
+		"file to also append the log to, $PADRON_LOG if set")
+}
+
+func run(c *Command, args []string) {
+	addFlags(c)
+	c.Flag.Parse(args)
+
+	if LogFile != "" {
+		f, err := os.OpenFile(LogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			log.Fatalf(`E: Can't open the log: %s. Abort.`, err)
+		}
+		log.SetOutput(io.MultiWriter(os.Stderr, f))
+	}
+
+	c.Run(c, c.Flag.Args())
+}
+
+// Main runs the command named by the first argument of the program.
+// Without one, or if the arguments start with a flag, it runs def,
+// as the program did before it had commands.
+func Main(commands []*Command, def *Command) {
+	args := os.Args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		prog = os.Args[0]
+		run(def, args)
+		return
+	}
+
+	name := args[0]
+	if name == "help" && len(args) == 2 {
+		name = args[1]
+	}
+	for _, c := range commands {
+		if c.Name == name {
+			prog = os.Args[0] + " " + c.Name
+			if args[0] == "help" {
+				addFlags(c)
+				c.Usage()
+				return
+			}
+			run(c, args[1:])
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage: %s command [flags] [arguments]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Commands:\n\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "    %-10s %s\n", c.Name, c.Short)
+	}
+	fmt.Fprintf(os.Stderr, "\nWithout a command, or with just flags, it runs %s.\n"+
+		"Run %s help <command> for the flags of a command.\n", def.Name, os.Args[0])
+	if name != "help" {
+		os.Exit(2)
+	}
+}
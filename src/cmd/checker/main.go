@@ -0,0 +1,10 @@
+package main
+
+import (
+	"cli"
+	"tse"
+)
+
+func main() {
+	cli.Run(tse.Check)
+}
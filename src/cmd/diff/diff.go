@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"model"
+	"os"
+	"sort"
+	"strconv"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: %s [flags] <before> <after>
+
+Lists the personas added, removed, moved to another junta or whose name
+was corrected between two padrones, each one a padron database,
+padron_completo.zip as published by the TSE or PADRON_COMPLETO.txt.
+
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+// Cambio is a difference between the two padrones.
+type Cambio struct {
+	Tipo           string
+	Cedula         string
+	Nombre         string
+	NombreAnterior string `json:",omitempty"`
+	JuntaAnterior  int64  `json:",omitempty"`
+	JuntaNueva     int64  `json:",omitempty"`
+	CentroAnterior string `json:",omitempty"`
+	CentroNuevo    string `json:",omitempty"`
+	Distrito       int64
+}
+
+// Resumen counts the changes in a distrito, the one of the new junta
+// except for bajas.
+type Resumen struct {
+	Distrito     int64  `json:",omitempty"`
+	Nombre       string `json:",omitempty"`
+	Altas        int
+	Bajas        int
+	Traslados    int
+	Correcciones int
+}
+
+func (r *Resumen) add(tipo string) {
+	switch tipo {
+	case model.CambioAlta:
+		r.Altas++
+	case model.CambioBaja:
+		r.Bajas++
+	case model.CambioTraslado:
+		r.Traslados++
+	case model.CambioCorreccion:
+		r.Correcciones++
+	}
+}
+
+// Order of the kinds of changes in the output
+var tipoOrder = map[string]int{
+	model.CambioAlta:       0,
+	model.CambioBaja:       1,
+	model.CambioTraslado:   2,
+	model.CambioCorreccion: 3,
+}
+
+type byTipo []Cambio
+
+func (s byTipo) Len() int      { return len(s) }
+func (s byTipo) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byTipo) Less(i, j int) bool {
+	if s[i].Tipo != s[j].Tipo {
+		return tipoOrder[s[i].Tipo] < tipoOrder[s[j].Tipo]
+	}
+	return s[i].Cedula < s[j].Cedula
+}
+
+// compare returns the changes from a to b, sorted by kind and cedula.
+func compare(a, b *snapshot) []Cambio {
+	var cambios []Cambio
+
+	for cedula, p := range b.personas {
+		old, ok := a.personas[cedula]
+		if !ok {
+			cambios = append(cambios, Cambio{
+				Tipo:        model.CambioAlta,
+				Cedula:      cedula,
+				Nombre:      p.nombre,
+				JuntaNueva:  p.junta,
+				CentroNuevo: p.centro,
+				Distrito:    p.distrito,
+			})
+			continue
+		}
+
+		if old.junta != p.junta {
+			cambios = append(cambios, Cambio{
+				Tipo:           model.CambioTraslado,
+				Cedula:         cedula,
+				Nombre:         p.nombre,
+				JuntaAnterior:  old.junta,
+				JuntaNueva:     p.junta,
+				CentroAnterior: old.centro,
+				CentroNuevo:    p.centro,
+				Distrito:       p.distrito,
+			})
+		}
+		if old.nombre != p.nombre {
+			cambios = append(cambios, Cambio{
+				Tipo:           model.CambioCorreccion,
+				Cedula:         cedula,
+				Nombre:         p.nombre,
+				NombreAnterior: old.nombre,
+				Distrito:       p.distrito,
+			})
+		}
+	}
+
+	for cedula, p := range a.personas {
+		if _, ok := b.personas[cedula]; !ok {
+			cambios = append(cambios, Cambio{
+				Tipo:           model.CambioBaja,
+				Cedula:         cedula,
+				Nombre:         p.nombre,
+				JuntaAnterior:  p.junta,
+				CentroAnterior: p.centro,
+				Distrito:       p.distrito,
+			})
+		}
+	}
+
+	sort.Sort(byTipo(cambios))
+	return cambios
+}
+
+// summarize counts cambios per distrito, named after b or else a.
+func summarize(cambios []Cambio, a, b *snapshot) []*Resumen {
+	seen := make(map[int64]*Resumen)
+	var resumen []*Resumen
+
+	for _, c := range cambios {
+		r, ok := seen[c.Distrito]
+		if !ok {
+			nombre, ok := b.distritos[c.Distrito]
+			if !ok {
+				nombre = a.distritos[c.Distrito]
+			}
+			r = &Resumen{Distrito: c.Distrito, Nombre: nombre}
+			seen[c.Distrito] = r
+			resumen = append(resumen, r)
+		}
+		r.add(c.Tipo)
+	}
+
+	sort.Sort(byDistrito(resumen))
+	return resumen
+}
+
+type byDistrito []*Resumen
+
+func (s byDistrito) Len() int           { return len(s) }
+func (s byDistrito) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byDistrito) Less(i, j int) bool { return s[i].Distrito < s[j].Distrito }
+
+func itoa(i int64) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.FormatInt(i, 10)
+}
+
+func writeCambiosCSV(w io.Writer, cambios []Cambio) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"tipo", "cedula", "nombre", "nombre_anterior",
+		"junta_anterior", "junta_nueva", "centro_anterior", "centro_nuevo",
+		"distrito"})
+	for _, c := range cambios {
+		cw.Write([]string{c.Tipo, c.Cedula, c.Nombre, c.NombreAnterior,
+			itoa(c.JuntaAnterior), itoa(c.JuntaNueva),
+			c.CentroAnterior, c.CentroNuevo, itoa(c.Distrito)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeResumenCSV(w io.Writer, resumen []*Resumen) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"distrito", "nombre", "altas", "bajas", "traslados",
+		"correcciones"})
+	for _, r := range resumen {
+		cw.Write([]string{itoa(r.Distrito), r.Nombre, strconv.Itoa(r.Altas),
+			strconv.Itoa(r.Bajas), strconv.Itoa(r.Traslados),
+			strconv.Itoa(r.Correcciones)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// create opens path for writing, "-" is stdout.
+func create(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(path)
+}
+
+func main() {
+	flag.Usage = usage
+	format := flag.String("format", "csv", "output format, csv or json")
+	out := flag.String("o", "-", "file to write the changes to")
+	resumenPath := flag.String("resumen", "",
+		"with -format csv, file to write the counts per distrito to")
+	antes := flag.String("antes", "",
+		"election of the padron before, if a database (default: the latest one)")
+	despues := flag.String("despues", "",
+		"election of the padron after, if a database (default: the latest one)")
+	flag.StringVar(&textEncoding, "encoding", "auto",
+		"encoding of the text files: auto, iso-8859-15, windows-1252 or utf-8")
+	flag.Parse()
+
+	if flag.NArg() != 2 || (*format != "csv" && *format != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var snaps [2]*snapshot
+	elecciones := []string{*antes, *despues}
+	for i, path := range flag.Args() {
+		s, err := readSnapshot(path, elecciones[i])
+		if err != nil {
+			log.Fatalf(`E: Can't read %s: %s. Abort.`, path, err)
+		}
+		log.Printf("I: %s: %d personas", path, len(s.personas))
+		if s.skipped > 0 {
+			log.Printf("W: %s: Skipped %d invalid lines", path, s.skipped)
+		}
+		snaps[i] = s
+	}
+
+	cambios := compare(snaps[0], snaps[1])
+	resumen := summarize(cambios, snaps[0], snaps[1])
+
+	var total Resumen
+	for _, c := range cambios {
+		total.add(c.Tipo)
+	}
+	log.Printf("I: %d altas, %d bajas, %d traslados, %d correcciones",
+		total.Altas, total.Bajas, total.Traslados, total.Correcciones)
+
+	w, err := create(*out)
+	if err != nil {
+		log.Fatalf(`E: Can't create %s: %s. Abort.`, *out, err)
+	}
+
+	if *format == "json" {
+		err = json.NewEncoder(w).Encode(struct {
+			Total     Resumen
+			Distritos []*Resumen
+			Cambios   []Cambio
+		}{total, resumen, cambios})
+	} else {
+		err = writeCambiosCSV(w, cambios)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		log.Fatalf(`E: Can't write %s: %s. Abort.`, *out, err)
+	}
+
+	if *resumenPath != "" {
+		w, err := create(*resumenPath)
+		if err == nil {
+			err = writeResumenCSV(w, resumen)
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		if err != nil {
+			log.Fatalf(`E: Can't write %s: %s. Abort.`, *resumenPath, err)
+		}
+	}
+}
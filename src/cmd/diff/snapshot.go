@@ -0,0 +1,295 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"model"
+	"os"
+	"parser"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// persona is a persona as listed in a padron.
+type persona struct {
+	nombre   string // nombre, apellido 1 and apellido 2
+	junta    int64
+	centro   string
+	distrito int64
+}
+
+// snapshot is a padron: the personas by cedula and the names of the
+// distritos by code.
+type snapshot struct {
+	personas  map[string]*persona
+	distritos map[int64]string
+	skipped   int // invalid padron lines
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{
+		personas:  make(map[string]*persona),
+		distritos: make(map[int64]string),
+	}
+}
+
+// readSnapshot reads the padron at path: a database built by the
+// parser, where it is the one of the election called eleccion (the
+// latest if empty), padron_completo.zip or PADRON_COMPLETO.txt.
+func readSnapshot(path, eleccion string) (*snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 16)
+	n, _ := io.ReadFull(f, magic)
+	magic = magic[:n]
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("SQLite format 3\x00")):
+		return readDatabase(path, eleccion)
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		return readZip(path)
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	s := newSnapshot()
+	return s, s.readPadron(f)
+}
+
+// The distrito of a persona is the one of the centro of their junta,
+// the padron table doesn't keep the one in the TSE files
+const databasePersonas = `SELECT
+		personas.cedula,
+		personas.nombre,
+		personas.apellido_1,
+		personas.apellido_2,
+		padron.junta_id,
+		COALESCE(centros.nombre, ''),
+		COALESCE(distritos_electorales.distrito_id, 0)
+	FROM
+		personas
+	JOIN
+		padron ON padron.persona_id = personas.id
+	LEFT JOIN
+		juntas ON juntas.eleccion_id = padron.eleccion_id AND juntas.id = padron.junta_id
+	LEFT JOIN
+		centros ON centros.eleccion_id = juntas.eleccion_id AND centros.id = juntas.centro_id
+	LEFT JOIN
+		distritos_electorales ON distritos_electorales.id = centros.distrito_electoral_id
+	WHERE padron.eleccion_id = ?`
+
+const databaseDistritos = `SELECT
+		distritos.id,
+		provincias.nombre,
+		cantones.nombre,
+		distritos.nombre
+	FROM
+		distritos
+	JOIN
+		cantones ON cantones.id = distritos.canton_id
+	JOIN
+		provincias ON provincias.id = cantones.provincia_id`
+
+func readDatabase(path, eleccion string) (*snapshot, error) {
+	dbmap, err := model.OpenDb(path)
+	if err != nil {
+		return nil, err
+	}
+	defer dbmap.Db.Close()
+	db := dbmap.Db
+
+	e, err := model.FindEleccion(dbmap, eleccion)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no election %q", eleccion)
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := newSnapshot()
+
+	rows, err := db.Query(databaseDistritos)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		var provincia, canton, distrito string
+		if err := rows.Scan(&id, &provincia, &canton, &distrito); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.distritos[id] = distritoName(provincia, canton, distrito)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(databasePersonas, e.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Many personas share a centro
+	centros := make(map[string]string)
+	for rows.Next() {
+		var cedula, nombre, apellido1, apellido2, centro string
+		p := &persona{}
+		err := rows.Scan(&cedula, &nombre, &apellido1, &apellido2,
+			&p.junta, &centro, &p.distrito)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := centros[centro]; ok {
+			centro = c
+		} else {
+			centros[centro] = centro
+		}
+		p.nombre = joinName(nombre, apellido1, apellido2)
+		p.centro = centro
+		s.personas[cedula] = p
+	}
+
+	return s, rows.Err()
+}
+
+// readZip reads PADRON_COMPLETO.txt and Distelec.txt from the ZIP
+// published by the TSE.
+func readZip(name string) (*snapshot, error) {
+	z, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	s := newSnapshot()
+	found := false
+	for _, f := range z.File {
+		var read func(io.Reader) error
+		switch strings.ToUpper(path.Base(f.Name)) {
+		case "PADRON_COMPLETO.TXT":
+			read = s.readPadron
+			found = true
+		case "DISTELEC.TXT":
+			read = s.readDistelec
+		default:
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = read(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", f.Name, err)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no PADRON_COMPLETO.txt")
+	}
+	return s, nil
+}
+
+// textEncoding is the encoding of the text files of the TSE, "auto" to
+// detect it like the import does.  Set from the command line.
+var textEncoding = "auto"
+
+// readLines calls f with the fields of each line of r, a text file of
+// the TSE in textEncoding.  Lines that can't be decoded, which the
+// parser rejects, have no fields.
+func readLines(r io.Reader, f func(fields []string) error) error {
+	r, _, err := parser.OpenText(r, textEncoding)
+	if err != nil {
+		return err
+	}
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var fields []string
+		if utf8.ValidString(line) {
+			fields = strings.Split(line, ",")
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if err := f(fields); err != nil {
+			return fmt.Errorf("line %d: %s", n, err)
+		}
+	}
+	return s.Err()
+}
+
+// readPadron reads a PADRON_COMPLETO.txt, skipping the lines the
+// parser would reject:
+//
+//	cedula,codele,sexo,vencimiento,junta,nombre,apellido 1,apellido 2
+func (s *snapshot) readPadron(r io.Reader) error {
+	return readLines(r, func(f []string) error {
+		if len(f) < 8 {
+			s.skipped++
+			return nil
+		}
+		if _, ok := s.personas[f[0]]; ok {
+			// Like the parser, the first one wins
+			return nil
+		}
+		junta, err := strconv.ParseInt(f[4], 10, 64)
+		if err != nil || junta == 0 {
+			s.skipped++
+			return nil
+		}
+		distrito, _ := strconv.ParseInt(f[1], 10, 64)
+		s.personas[f[0]] = &persona{
+			nombre:   joinName(f[5], f[6], f[7]),
+			junta:    junta,
+			distrito: distrito,
+		}
+		return nil
+	})
+}
+
+// readDistelec reads the names in a Distelec.txt, the parser reports
+// the lines it skips:
+//
+//	codele,provincia,canton,distrito
+func (s *snapshot) readDistelec(r io.Reader) error {
+	return readLines(r, func(f []string) error {
+		if len(f) != 4 {
+			return nil
+		}
+		id, err := strconv.ParseInt(f[0], 10, 64)
+		if _, ok := s.distritos[id]; err != nil || ok {
+			return nil
+		}
+		s.distritos[id] = distritoName(f[1], f[2], f[3])
+		return nil
+	})
+}
+
+func joinName(nombre, apellido1, apellido2 string) string {
+	return strings.Join(strings.Fields(nombre+" "+apellido1+" "+apellido2), " ")
+}
+
+func distritoName(provincia, canton, distrito string) string {
+	return provincia + " / " + canton + " / " + distrito
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Real provincias, cantones and distritos, a few of each.  Distritos
+// get their code from their position, so only leading ones are listed.
+const geografia = `
+1 SAN JOSE
+	01 CENTRAL: CARMEN, MERCED, HOSPITAL, CATEDRAL, ZAPOTE, SAN FRANCISCO DE DOS RIOS, URUCA, MATA REDONDA, PAVAS, HATILLO, SAN SEBASTIAN
+	02 ESCAZU: ESCAZU, SAN ANTONIO, SAN RAFAEL
+	03 DESAMPARADOS: DESAMPARADOS, SAN MIGUEL, SAN JUAN DE DIOS, SAN RAFAEL ARRIBA, SAN ANTONIO, FRAILES, PATARRA, SAN CRISTOBAL, ROSARIO, DAMAS, SAN RAFAEL ABAJO, GRAVILIAS, LOS GUIDO
+	06 ASERRI: ASERRI, TARBACA, VUELTA DE JORCO, SAN GABRIEL, LEGUA, MONTERREY, SALITRILLOS
+	08 GOICOECHEA: GUADALUPE, SAN FRANCISCO, CALLE BLANCOS, MATA DE PLATANO, IPIS, RANCHO REDONDO, PURRAL
+2 ALAJUELA
+	01 ALAJUELA: ALAJUELA, SAN JOSE, CARRIZAL, SAN ANTONIO, GUACIMA, SAN ISIDRO, SABANILLA, SAN RAFAEL, RIO SEGUNDO, DESAMPARADOS, TURRUCARES, TAMBOR, GARITA, SARAPIQUI
+	02 SAN RAMON: SAN RAMON, SANTIAGO, SAN JUAN, PIEDADES NORTE, PIEDADES SUR, SAN RAFAEL, SAN ISIDRO, ANGELES, ALFARO, VOLIO, CONCEPCION, ZAPOTAL, PEÑAS BLANCAS
+	03 GRECIA: GRECIA, SAN ISIDRO, SAN JOSE, SAN ROQUE, TACARES
+	10 SAN CARLOS: QUESADA, FLORENCIA, BUENAVISTA, AGUAS ZARCAS, VENECIA, PITAL, FORTUNA, TIGRA, PALMERA, VENADO, CUTRIS, MONTERREY, POCOSOL
+3 CARTAGO
+	01 CARTAGO: ORIENTAL, OCCIDENTAL, CARMEN, SAN NICOLAS, AGUACALIENTE O SAN FRANCISCO, GUADALUPE O ARENILLA, CORRALILLO, TIERRA BLANCA, DULCE NOMBRE, LLANO GRANDE, QUEBRADILLA
+	02 PARAISO: PARAISO, SANTIAGO, OROSI, CACHI, LLANOS DE SANTA LUCIA
+	03 LA UNION: TRES RIOS, SAN DIEGO, SAN JUAN, SAN RAFAEL, CONCEPCION, DULCE NOMBRE, SAN RAMON, RIO AZUL
+	05 TURRIALBA: TURRIALBA, LA SUIZA, PERALTA, SANTA CRUZ, SANTA TERESITA, PAVONES, TUIS, TAYUTIC, SANTA ROSA, TRES EQUIS, LA ISABEL, CHIRRIPO
+4 HEREDIA
+	01 HEREDIA: HEREDIA, MERCEDES, SAN FRANCISCO, ULLOA, VARABLANCA
+	02 BARVA: BARVA, SAN PEDRO, SAN PABLO, SAN ROQUE, SANTA LUCIA, SAN JOSE DE LA MONTAÑA
+	03 SANTO DOMINGO: SANTO DOMINGO, SAN VICENTE, SAN MIGUEL, PARACITO, SANTO TOMAS, SANTA ROSA, TURES, PARA
+5 GUANACASTE
+	01 LIBERIA: LIBERIA, CAÑAS DULCES, MAYORGA, NACASCOLO, CURUBANDE
+	02 NICOYA: NICOYA, MANSION, SAN ANTONIO, QUEBRADA HONDA, SAMARA, NOSARA, BELEN DE NOSARITA
+	03 SANTA CRUZ: SANTA CRUZ, BOLSON, VEINTISIETE DE ABRIL, TEMPATE, CARTAGENA, CUAJINIQUIL, DIRIA, CABO VELAS, TAMARINDO
+6 PUNTARENAS
+	01 PUNTARENAS: PUNTARENAS, PITAHAYA, CHOMES, LEPANTO, PAQUERA, MANZANILLO, GUACIMAL, BARRANCA, MONTE VERDE
+	02 ESPARZA: ESPIRITU SANTO, SAN JUAN GRANDE, MACACONA, SAN RAFAEL, SAN JERONIMO
+	03 BUENOS AIRES: BUENOS AIRES, VOLCAN, POTRERO GRANDE, BORUCA, PILAS, COLINAS, CHANGUENA, BIOLLEY, BRUNKA
+7 LIMON
+	01 LIMON: LIMON, VALLE LA ESTRELLA, RIO BLANCO, MATAMA
+	02 POCOCI: GUAPILES, JIMENEZ, RITA, ROXANA, CARIARI, COLORADO, LA COLONIA
+	03 SIQUIRRES: SIQUIRRES, PACUARITO, FLORIDA, GERMANIA, EL CAIRO, ALEGRIA
+`
+
+// Names, most frequent first, as the TSE writes them: upper case,
+// without accents but with Ñ
+var (
+	nombresHombre = strings.Fields(`JOSE JUAN LUIS CARLOS JORGE MARIO
+		FRANCISCO MANUEL JOSUE DANIEL RANDALL ROBERTO MIGUEL RAFAEL DAVID
+		ALEJANDRO ANDRES RICARDO OSCAR MARVIN EDUARDO DIEGO GERARDO JAVIER
+		ALONSO KEVIN FERNANDO SERGIO ESTEBAN MAURICIO ANTONIO VICTOR PABLO
+		MINOR GREIVIN JONATHAN ALBERTO ADRIAN SEBASTIAN GILBERTO`)
+	nombresMujer = strings.Fields(`MARIA ANA LAURA SOFIA CAROLINA DANIELA
+		ANDREA MARCELA GABRIELA ADRIANA SILVIA PATRICIA KATHERINE VALERIA
+		NATALIA MARTA ROSA FLOR JENNIFER KARLA MARIANA PAOLA TATIANA MELISSA
+		MONICA GRACIELA VANESSA ELENA YORLENY XINIA SONIA LUCIA ISABEL CINTHYA
+		ALEJANDRA JULIA CRISTINA DAYANA FABIOLA LORENA`)
+	apellidos = strings.Fields(`RODRIGUEZ VARGAS JIMENEZ MORA ROJAS GONZALEZ
+		SANCHEZ HERNANDEZ CASTRO RAMIREZ ALVARADO CHAVES ARAYA SOLANO LOPEZ
+		SALAS CAMPOS QUESADA MORALES MURILLO VILLALOBOS CORDERO GUTIERREZ
+		SOLIS BRENES CASTILLO ARIAS MENDEZ ESQUIVEL CALDERON NUÑEZ ZUÑIGA
+		ULATE VEGA ACUÑA PEÑA MUÑOZ MONGE BARRANTES CHACON AGUILAR CERDAS
+		FONSECA PORRAS VINDAS CARVAJAL GAMBOA MADRIGAL SEGURA BOLAÑOS MATA
+		LEIVA ALFARO VALVERDE PICADO UGALDE CASCANTE GARITA OVIEDO OBANDO`)
+)
+
+// Names of the distritos electorales other than the main one of each
+// distrito
+var localidades = []string{"SAN RAFAEL", "SAN JUAN", "SAN ISIDRO",
+	"LA GUARIA", "LOS ANGELES", "EL CARMEN", "SANTA ROSA", "LA ESPERANZA",
+	"BARRIO NUEVO", "SAN MARTIN", "LOS LAGOS", "EL ROBLE", "LA CRUZ",
+	"SANTA ELENA", "CONCEPCION", "LA PALMA", "EL HIGUERON", "SAN LUIS",
+	"LAS MERCEDES", "BELLA VISTA", "EL PROGRESO", "VILLA BONITA",
+	"LA LUCHA", "SAN MIGUEL", "EL CAMPO"}
+
+// Kinds of centros de votacion, most frequent first, and their names
+var (
+	tiposCentro   = []string{"ESCUELA", "LICEO", "COLEGIO", "CENTRO EDUCATIVO", "SALON COMUNAL"}
+	nombresCentro = []string{"JUAN RAFAEL MORA PORRAS", "JOSE FIGUERES FERRER",
+		"REPUBLICA DE MEXICO", "MAURO FERNANDEZ", "LEON CORTES CASTRO",
+		"RICARDO JIMENEZ OREAMUNO", "OMAR DENGO", "CARMEN LYRA",
+		"JUAN SANTAMARIA", "MIGUEL OBREGON", "JOSE MARIA ZELEDON",
+		"BRAULIO CARRILLO", "MARIA AUXILIADORA", "CLETO GONZALEZ VIQUEZ",
+		"JESUS JIMENEZ"}
+)
+
+type distrito struct {
+	id        string // PCCDDD
+	provincia string
+	canton    string
+	nombre    string
+	des       []*de
+}
+
+// de is a distrito electoral.
+type de struct {
+	id        string // PCCDDDNNN
+	nombre    string
+	distrito  *distrito
+	electores int
+	juntas    []*junta
+	centros   []*centro
+}
+
+type junta struct {
+	id        int
+	electores int
+	de        *de
+}
+
+type centro struct {
+	tipo      string
+	nombre    string
+	de        *de
+	inicial   int
+	final     int
+	direccion string
+	url       string
+}
+
+// readGeografia parses geografia.
+func readGeografia() ([]*distrito, error) {
+	var distritos []*distrito
+	var provincia string
+
+	s := bufio.NewScanner(strings.NewReader(geografia))
+	for s.Scan() {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "\t") {
+			provincia = line
+			continue
+		}
+
+		f := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(f) != 2 || provincia == "" {
+			return nil, fmt.Errorf("bad line %q", line)
+		}
+		canton := f[0]
+		for i, nombre := range strings.Split(f[1], ",") {
+			distritos = append(distritos, &distrito{
+				id:        fmt.Sprintf("%s%s%03d", provincia[:1], canton[:2], i+1),
+				provincia: provincia,
+				canton:    canton,
+				nombre:    strings.TrimSpace(nombre),
+			})
+		}
+	}
+	return distritos, s.Err()
+}
+
+// zipf picks from lists ordered by frequency.
+type zipf struct {
+	z    *rand.Zipf
+	list []string
+}
+
+func newZipf(r *rand.Rand, list []string) *zipf {
+	return &zipf{rand.NewZipf(r, 1.1, 3, uint64(len(list)-1)), list}
+}
+
+func (z *zipf) pick() string {
+	return z.list[z.z.Uint64()]
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: %s [flags]
+
+Writes a fake padron with the files published by the TSE, for tests
+and demos: padron_completo.zip (PADRON_COMPLETO.txt and Distelec.txt),
+Distelec.txt, centros.xlsx and juntas.xlsx.  The same flags always give
+the same files.
+
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+// persona is a line of the padron.
+type persona struct {
+	cedula int64
+	junta  *junta
+}
+
+type byCedula []persona
+
+func (s byCedula) Len() int           { return len(s) }
+func (s byCedula) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byCedula) Less(i, j int) bool { return s[i].cedula < s[j].cedula }
+
+// plan is what is generated: the distritos with electores, in order of
+// code, and their juntas and centros.
+type plan struct {
+	distritos []*distrito
+	juntas    []*junta
+	centros   []*centro
+}
+
+// makePlan spreads personas across distritos electorales picked from
+// all, a fairly random number of them in each, and groups them in
+// juntas of at most porJunta electores and these in centros.  Bigger
+// padrones use more distritos.
+func makePlan(r *rand.Rand, all []*distrito, personas, porJunta int) *plan {
+	// The first distrito of each provincia, and then any
+	n := personas / 2000
+	var picked, rest []*distrito
+	for i, d := range all {
+		if i == 0 || d.provincia != all[i-1].provincia {
+			picked = append(picked, d)
+		} else {
+			rest = append(rest, d)
+		}
+	}
+	for _, i := range r.Perm(len(rest)) {
+		if len(picked) >= n {
+			break
+		}
+		picked = append(picked, rest[i])
+	}
+	sort.Sort(byId(picked))
+
+	var des []*de
+	var weights []float64
+	total := 0.0
+	for _, d := range picked {
+		names := r.Perm(len(localidades))
+		k := 1 + r.Intn(3)
+		for i := 0; i < k; i++ {
+			nombre := d.nombre
+			if i > 0 {
+				nombre = localidades[names[i]]
+			}
+			e := &de{
+				id:       fmt.Sprintf("%s%03d", d.id, i+1),
+				nombre:   nombre,
+				distrito: d,
+			}
+			d.des = append(d.des, e)
+			des = append(des, e)
+
+			w := 0.2 + r.ExpFloat64()
+			weights = append(weights, w)
+			total += w
+		}
+	}
+
+	left := personas
+	for i, e := range des {
+		e.electores = int(float64(personas) * weights[i] / total)
+		left -= e.electores
+	}
+	for i := 0; left > 0; i = (i + 1) % len(des) {
+		des[i].electores++
+		left--
+	}
+
+	p := &plan{}
+	for _, d := range picked {
+		var used []*de
+		for _, e := range d.des {
+			if e.electores == 0 {
+				continue
+			}
+			used = append(used, e)
+
+			k := (e.electores + porJunta - 1) / porJunta
+			for i := 0; i < k; i++ {
+				j := &junta{
+					id:        len(p.juntas) + 1,
+					electores: e.electores / k,
+					de:        e,
+				}
+				if i < e.electores%k {
+					j.electores++
+				}
+				e.juntas = append(e.juntas, j)
+				p.juntas = append(p.juntas, j)
+			}
+
+			for i := 0; i < len(e.juntas); {
+				size := 1 + r.Intn(6)
+				if i+size > len(e.juntas) {
+					size = len(e.juntas) - i
+				}
+				c := newCentro(r, e, len(e.centros))
+				c.inicial = e.juntas[i].id
+				c.final = e.juntas[i+size-1].id
+				e.centros = append(e.centros, c)
+				p.centros = append(p.centros, c)
+				i += size
+			}
+		}
+		d.des = used
+		if len(used) > 0 {
+			p.distritos = append(p.distritos, d)
+		}
+	}
+
+	return p
+}
+
+type byId []*distrito
+
+func (s byId) Len() int           { return len(s) }
+func (s byId) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byId) Less(i, j int) bool { return s[i].id < s[j].id }
+
+var rumbos = []string{"NORTE", "SUR", "ESTE", "OESTE"}
+
+// newCentro returns the n-th centro of e, with a name of its own in e.
+func newCentro(r *rand.Rand, e *de, n int) *centro {
+	tipo := tiposCentro[0]
+	if r.Intn(3) == 0 {
+		tipo = tiposCentro[1+r.Intn(len(tiposCentro)-1)]
+	}
+
+	nombre := "DE " + e.nombre
+	if n > 0 || r.Intn(2) == 0 {
+		nombre = nombresCentro[(r.Intn(len(nombresCentro))+n)%len(nombresCentro)]
+	}
+	// Names are unique in a distrito electoral, whatever the tipo
+	for _, c := range e.centros {
+		if c.nombre == nombre {
+			nombre = fmt.Sprintf("%s %d", nombre, n+1)
+		}
+	}
+
+	// Somewhere in Costa Rica
+	lat := 8.3 + 2.5*r.Float64()
+	lng := -85.6 + 3*r.Float64()
+
+	return &centro{
+		tipo:   tipo,
+		nombre: nombre,
+		de:     e,
+		direccion: fmt.Sprintf("%d METROS AL %s DE LA IGLESIA CATOLICA DE %s",
+			100*(1+r.Intn(5)), rumbos[r.Intn(len(rumbos))], e.nombre),
+		url: fmt.Sprintf("https://maps.google.com/?q=%.5f,%.5f", lat, lng),
+	}
+}
+
+// makePersonas returns the personas of the juntas in p, sorted by
+// cedula like the padron of the TSE.  Most were born in the provincia
+// they vote in, their cedula starts with its number, a few are
+// naturalized, 8.
+func makePersonas(r *rand.Rand, p *plan) []persona {
+	n := 0
+	for _, j := range p.juntas {
+		n += j.electores
+	}
+
+	seen := make(map[int64]bool, n)
+	personas := make([]persona, 0, n)
+	for _, j := range p.juntas {
+		local := int64(j.de.distrito.id[0] - '0')
+		for i := 0; i < j.electores; i++ {
+			var cedula int64
+			for cedula == 0 || seen[cedula] {
+				provincia := local
+				switch x := r.Intn(100); {
+				case x < 5:
+					provincia = 8
+				case x < 20:
+					provincia = 1 + r.Int63n(7)
+				}
+				// Tomo and asiento
+				cedula = provincia*1e8 + (1+r.Int63n(1999))*1e4 + 1 + r.Int63n(1200)
+			}
+			seen[cedula] = true
+			personas = append(personas, persona{cedula, j})
+		}
+	}
+
+	sort.Sort(byCedula(personas))
+	return personas
+}
+
+// injector decides which rows of the files get an error, at rate, and
+// counts them.
+type injector struct {
+	r      *rand.Rand
+	rate   float64
+	counts map[string]map[string]int // by file and kind
+}
+
+// inject returns the kind of error, one of kinds, the next row of file
+// gets, "" for none.  A nil injector injects none.
+func (in *injector) inject(file string, kinds ...string) string {
+	if in == nil || in.rate <= 0 || in.r.Float64() >= in.rate {
+		return ""
+	}
+	kind := kinds[in.r.Intn(len(kinds))]
+	if in.counts[file] == nil {
+		in.counts[file] = make(map[string]int)
+	}
+	in.counts[file][kind]++
+	return kind
+}
+
+func (in *injector) report() {
+	files := make([]string, 0, len(in.counts))
+	for f := range in.counts {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		var kinds []string
+		n := 0
+		for k := range in.counts[f] {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		for i, k := range kinds {
+			n += in.counts[f][k]
+			kinds[i] = fmt.Sprintf("%d %s", in.counts[f][k], k)
+		}
+		log.Printf("I: %s: injected %d errors: %s", f, n, strings.Join(kinds, ", "))
+	}
+}
+
+func main() {
+	flag.Usage = usage
+	dir := flag.String("o", ".", "directory to write the files to")
+	n := flag.Int("personas", 10000, "number of personas in the padron")
+	porJunta := flag.Int("electores", 500, "most electores per junta")
+	seed := flag.Int64("seed", 1, "seed of the random data")
+	rate := flag.Float64("errores", 0,
+		"fraction of lines and rows written with an error, like the ones found in the TSE files")
+	fecha := flag.String("fecha", "2018-02-04",
+		"date of the padron (YYYY-MM-DD), cedulas expire up to ten years later")
+	format := flag.String("format", "xlsx", "format of the centros and juntas lists, xlsx or csv")
+	serve := flag.String("serve", "",
+		"address to answer on, once written, like the DondeVotar service of the TSE, for bin/scraper")
+	flag.Parse()
+
+	if flag.NArg() != 0 || *n < 1 || *porJunta < 1 || (*format != "xlsx" && *format != "csv") {
+		usage()
+		os.Exit(2)
+	}
+
+	corte, err := time.Parse("2006-01-02", *fecha)
+	if err != nil {
+		log.Fatalf(`E: Bad date %q: %s. Abort.`, *fecha, err)
+	}
+
+	distritos, err := readGeografia()
+	if err != nil {
+		log.Fatalf(`E: Can't read the geography: %s. Abort.`, err)
+	}
+
+	r := rand.New(rand.NewSource(*seed))
+	errs := &injector{
+		r:      rand.New(rand.NewSource(*seed + 1)),
+		rate:   math.Min(*rate, 1),
+		counts: make(map[string]map[string]int),
+	}
+
+	p := makePlan(r, distritos, *n, *porJunta)
+	personas := makePersonas(r, p)
+	log.Printf("I: %d personas in %d juntas, %d centros and %d distritos",
+		len(personas), len(p.juntas), len(p.centros), len(p.distritos))
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		log.Fatalf(`E: Can't create %s: %s. Abort.`, *dir, err)
+	}
+
+	var donde map[string]*centro
+	if *serve != "" {
+		donde = make(map[string]*centro, len(personas))
+	}
+
+	zipPath := filepath.Join(*dir, "padron_completo.zip")
+	err = writeZip(zipPath, corte, func(w *zipWriter) error {
+		err := w.text("PADRON_COMPLETO.txt", func(w *lineWriter) error {
+			return writePadron(w, r, errs, personas, p, corte, donde)
+		})
+		if err != nil {
+			return err
+		}
+		return w.text("Distelec.txt", func(w *lineWriter) error {
+			return writeDistelec(w, errs, p.distritos)
+		})
+	})
+	if err != nil {
+		log.Fatalf(`E: Can't write %s: %s. Abort.`, zipPath, err)
+	}
+
+	distelecPath := filepath.Join(*dir, "Distelec.txt")
+	err = writeText(distelecPath, func(w *lineWriter) error {
+		return writeDistelec(w, nil, p.distritos)
+	})
+	if err != nil {
+		log.Fatalf(`E: Can't write %s: %s. Abort.`, distelecPath, err)
+	}
+
+	centrosPath := filepath.Join(*dir, "centros."+*format)
+	if err := writeSheet(centrosPath, "Centros", centrosRows(errs, p)); err != nil {
+		log.Fatalf(`E: Can't write %s: %s. Abort.`, centrosPath, err)
+	}
+
+	juntasPath := filepath.Join(*dir, "juntas."+*format)
+	if err := writeSheet(juntasPath, "Juntas", juntasRows(errs, p)); err != nil {
+		log.Fatalf(`E: Can't write %s: %s. Abort.`, juntasPath, err)
+	}
+
+	errs.report()
+	log.Printf("I: Wrote %s, %s, %s and %s", zipPath, distelecPath, centrosPath, juntasPath)
+
+	if *serve != "" {
+		log.Printf("I: Answering for the centros on %s", *serve)
+		log.Fatal(serveDondeVotar(*serve, donde))
+	}
+}
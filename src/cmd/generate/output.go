@@ -0,0 +1,287 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tealeg/xlsx"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// lineWriter writes CRLF terminated lines in ISO-8859-15, like the
+// text files of the TSE.  The first error is kept in err.
+type lineWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func newLineWriter(w io.Writer) *lineWriter {
+	return &lineWriter{w: bufio.NewWriter(charmap.ISO8859_15.NewEncoder().Writer(w))}
+}
+
+func (w *lineWriter) line(s string) {
+	if w.err == nil {
+		_, w.err = w.w.WriteString(s + "\r\n")
+	}
+}
+
+func (w *lineWriter) flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+// writeText writes the text file at path with f.
+func writeText(path string, f func(*lineWriter) error) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := newLineWriter(out)
+	if err := f(w); err != nil {
+		out.Close()
+		return err
+	}
+	if err := w.flush(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+type zipWriter struct {
+	z       *zip.Writer
+	changed time.Time
+}
+
+// text adds the text file called name, written with f.
+func (z *zipWriter) text(name string, f func(*lineWriter) error) error {
+	h := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+	h.SetModTime(z.changed)
+	out, err := z.z.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	w := newLineWriter(out)
+	if err := f(w); err != nil {
+		return err
+	}
+	return w.flush()
+}
+
+// writeZip writes the ZIP file at path with f, its files changed at
+// changed.
+func writeZip(path string, changed time.Time, f func(*zipWriter) error) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	z := zip.NewWriter(out)
+	if err := f(&zipWriter{z, changed}); err != nil {
+		out.Close()
+		return err
+	}
+	if err := z.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writePadron writes a line per persona:
+//
+//	cedula,codele,sexo,vencimiento,junta,nombre,apellido 1,apellido 2
+//
+// the names padded with spaces to 30, 26 and 26 characters.  The
+// personas with a correct line are added to donde, if not nil.
+func writePadron(w *lineWriter, r *rand.Rand, errs *injector, personas []persona,
+	p *plan, corte time.Time, donde map[string]*centro) error {
+
+	hombres := newZipf(r, nombresHombre)
+	mujeres := newZipf(r, nombresMujer)
+	familia := newZipf(r, apellidos)
+
+	centros := make(map[*junta]*centro)
+	for _, c := range p.centros {
+		for _, j := range c.de.juntas {
+			if j.id >= c.inicial && j.id <= c.final {
+				centros[j] = c
+			}
+		}
+	}
+
+	const file = "PADRON_COMPLETO.txt"
+	for i, per := range personas {
+		sexo, nombres := 1, hombres
+		if r.Intn(2) == 0 {
+			sexo, nombres = 2, mujeres
+		}
+		nombre := nombres.pick()
+		if r.Intn(3) == 0 {
+			if otro := nombres.pick(); otro != nombre {
+				nombre += " " + otro
+			}
+		}
+		// Cedulas last ten years, a few are expired
+		vence := corte.AddDate(0, 0, r.Intn(10*365+180)-180)
+
+		cedula := strconv.FormatInt(per.cedula, 10)
+		junta := per.junta.id
+		fields := []string{
+			cedula,
+			per.junta.de.distrito.id,
+			strconv.Itoa(sexo),
+			vence.Format("20060102"),
+			"",
+			fmt.Sprintf("%-30s", nombre),
+			fmt.Sprintf("%-26s", familia.pick()),
+			fmt.Sprintf("%-26s", familia.pick()),
+		}
+
+		// The first line tells the parser what the file is
+		kind := ""
+		if i > 0 {
+			kind = errs.inject(file, "campos", "cedula", "junta", "duplicada", "desconocida")
+		}
+		switch kind {
+		case "campos":
+			fields = fields[:5+r.Intn(3)]
+		case "cedula":
+			fields[0] = strings.Repeat("0", len(cedula))
+		case "junta":
+			junta = 0
+		case "desconocida":
+			junta = len(p.juntas) + 1 + r.Intn(100)
+		}
+		if len(fields) > 4 {
+			fields[4] = fmt.Sprintf("%05d", junta)
+		}
+		w.line(strings.Join(fields, ","))
+
+		switch kind {
+		case "":
+			if donde != nil {
+				donde[cedula] = centros[per.junta]
+			}
+		case "duplicada":
+			// Listed again, in another junta
+			fields[4] = fmt.Sprintf("%05d", 1+r.Intn(len(p.juntas)))
+			w.line(strings.Join(fields, ","))
+			if donde != nil {
+				donde[cedula] = centros[per.junta]
+			}
+		}
+	}
+	return w.err
+}
+
+// writeDistelec writes a line per distrito:
+//
+//	codele,provincia,canton,distrito
+func writeDistelec(w *lineWriter, errs *injector, distritos []*distrito) error {
+	for _, d := range distritos {
+		fields := []string{d.id, d.provincia[2:], d.canton[3:], d.nombre}
+		if errs.inject("Distelec.txt", "campos") != "" {
+			fields = fields[:3]
+		}
+		w.line(strings.Join(fields, ","))
+	}
+	return w.err
+}
+
+// titled returns the first rows of a list: a title, like the ones of
+// the TSE, and header.
+func titled(header ...interface{}) [][]interface{} {
+	return [][]interface{}{{"TRIBUNAL SUPREMO DE ELECCIONES"}, {}, header}
+}
+
+func centrosRows(errs *injector, p *plan) [][]interface{} {
+	rows := titled("Código", "Provincia", "Cantón", "Distrito Electoral",
+		"JRV Inicial", "JRV Final", "Total JRV", "Tipo", "Nombre")
+
+	for _, c := range p.centros {
+		d := c.de.distrito
+		total := c.final - c.inicial + 1
+		if errs.inject("centros", "total") != "" {
+			total++
+		}
+		rows = append(rows, []interface{}{d.id, d.provincia[2:], d.canton[3:],
+			c.de.nombre, c.inicial, c.final, total, c.tipo, c.tipo + " " + c.nombre})
+	}
+	return rows
+}
+
+func juntasRows(errs *injector, p *plan) [][]interface{} {
+	rows := titled("Provincia", "Cantón", "Distrito", "Distrito Electoral",
+		"Junta", "Electores")
+
+	for _, j := range p.juntas {
+		d := j.de.distrito
+		var electores interface{} = j.electores
+		switch errs.inject("juntas", "electores", "conteo") {
+		case "electores":
+			electores = "N/D"
+		case "conteo":
+			electores = j.electores + 1 + errs.r.Intn(5)
+		}
+		rows = append(rows, []interface{}{d.provincia, d.canton,
+			d.id[3:] + " " + d.nombre, j.de.id + " " + j.de.nombre, j.id, electores})
+	}
+	return rows
+}
+
+// writeSheet writes rows, of strings and ints, as a sheet called name
+// of the spreadsheet at path: XLSX or, if it ends in .csv, CSV.
+func writeSheet(path, name string, rows [][]interface{}) error {
+	if strings.HasSuffix(path, ".csv") {
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		w := csv.NewWriter(out)
+		for _, row := range rows {
+			rec := make([]string, len(row))
+			for i, v := range row {
+				rec[i] = fmt.Sprint(v)
+			}
+			w.Write(rec)
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+
+	f := xlsx.NewFile()
+	sheet, err := f.AddSheet(name)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		r := sheet.AddRow()
+		for _, v := range row {
+			c := r.AddCell()
+			switch v := v.(type) {
+			case int:
+				c.SetInt(v)
+			default:
+				c.SetString(fmt.Sprint(v))
+			}
+		}
+	}
+	return f.Save(path)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// dondeVotarPath is where the TSE answers which centro a cedula votes
+// in, see bin/scraper.
+const dondeVotarPath = "/DondeVotarM/prRemoto.aspx/ObtenerDondeVotar"
+
+// serveDondeVotar answers on addr like the DondeVotar service of the
+// TSE for the centros in donde, by cedula.
+func serveDondeVotar(addr string, donde map[string]*centro) error {
+	http.HandleFunc(dondeVotarPath, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			NumeroCedula string `json:"numeroCedula"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		type lista struct {
+			CodElectoral         int
+			DireccionEscuela     string
+			NombreCentroVotacion string
+			Url                  string
+			DescripcionProvincia string
+			DescripcionCanton    string
+			DescripcionDistrito  string
+		}
+		var res struct {
+			D struct {
+				Lista lista
+			} `json:"d"`
+		}
+
+		// Unknown cedulas get an empty answer, like from the TSE
+		if c, ok := donde[req.NumeroCedula]; ok && c != nil {
+			codigo, _ := strconv.Atoi(c.de.distrito.id)
+			d := c.de.distrito
+			res.D.Lista = lista{codigo, c.direccion, c.tipo + " " + c.nombre, c.url,
+				d.provincia[2:], d.canton[3:], c.de.nombre}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			log.Printf("W: Can't answer for %s: %s", req.NumeroCedula, err)
+		}
+	})
+
+	return http.ListenAndServe(addr, nil)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"model"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: %s [flags]
+
+Brings the schema of the database up to date, creating it if it doesn't
+exist.
+
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	path := flag.String("db", "padron.db", "database to migrate")
+	status := flag.Bool("status", false, "only print the schema version")
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	if *status {
+		if _, err := os.Stat(*path); err != nil {
+			log.Fatalf(`E: Can't open %s: %s. Abort.`, *path, err)
+		}
+	}
+
+	db, err := model.Open(*path)
+	if err != nil {
+		log.Fatalf(`E: Can't open %s: %s. Abort.`, *path, err)
+	}
+	defer db.Close()
+
+	if *status {
+		v, err := model.Version(db)
+		if err != nil {
+			log.Fatalf(`E: Can't read the schema version: %s. Abort.`, err)
+		}
+		fmt.Printf("%s: schema version %d, this program's is %d\n",
+			*path, v, model.SchemaVersion)
+		return
+	}
+
+	from, err := model.Migrate(db)
+	if err != nil {
+		log.Fatalf(`E: Can't migrate %s: %s. Abort.`, *path, err)
+	}
+	if from == model.SchemaVersion {
+		log.Printf("I: %s: already at schema version %d", *path, from)
+	} else {
+		log.Printf("I: %s: schema version %d to %d", *path, from, model.SchemaVersion)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"cli"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"model"
+	"os"
+)
+
+var cmdExport = &cli.Command{
+	Name:  "export",
+	Short: "write the padron of an election as CSV or JSON",
+	Long: `
+Writes a row per persona in the padron of an election, sorted by cedula,
+with the junta and centro they vote in and its distrito electoral,
+distrito, canton and provincia: CSV with a header row or, with -format
+json, a JSON object per line.
+`,
+}
+
+var (
+	exportEleccion = cmdExport.Flag.String("eleccion", "",
+		"election to export, e.g. 2018 (default: the latest one)")
+	exportFormat = cmdExport.Flag.String("format", "csv", "csv or json")
+	exportOut    = cmdExport.Flag.String("o", "", "file to write to (default: standard output)")
+)
+
+func init() {
+	cmdExport.Run = runExport
+}
+
+var exportColumns = []string{"cedula", "nombre", "apellido_1", "apellido_2",
+	"genero", "expiracion", "junta", "tipo", "centro", "direccion", "url",
+	"distrito_electoral", "distrito", "canton", "provincia"}
+
+// exportWriter writes the rows of the padron in a format.
+type exportWriter interface {
+	write(row []string) error
+	flush() error
+}
+
+type csvExport struct{ w *csv.Writer }
+
+func (e csvExport) write(row []string) error { return e.w.Write(row) }
+func (e csvExport) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonExport struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e jsonExport) write(row []string) error {
+	obj := make(map[string]string, len(row))
+	for i, v := range row {
+		obj[exportColumns[i]] = v
+	}
+	return e.enc.Encode(obj)
+}
+func (e jsonExport) flush() error { return e.w.Flush() }
+
+func runExport(cmd *cli.Command, args []string) {
+	if len(args) != 0 || (*exportFormat != "csv" && *exportFormat != "json") {
+		cmd.Fail()
+	}
+
+	dbmap := cli.OpenDb()
+	defer dbmap.Db.Close()
+
+	e, err := model.FindEleccion(dbmap, *exportEleccion)
+	if err != nil {
+		log.Fatalf(`E: Can't find the election: %s. Abort.`, err)
+	}
+
+	var out io.Writer = os.Stdout
+	if *exportOut != "" {
+		f, err := os.Create(*exportOut)
+		if err != nil {
+			log.Fatalf(`E: Can't create %s: %s. Abort.`, *exportOut, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	var w exportWriter
+	switch *exportFormat {
+	case "csv":
+		c := csv.NewWriter(out)
+		if err := c.Write(exportColumns); err != nil {
+			log.Fatalf(`E: Can't write: %s. Abort.`, err)
+		}
+		w = csvExport{c}
+	case "json":
+		b := bufio.NewWriter(out)
+		w = jsonExport{b, json.NewEncoder(b)}
+	}
+
+	// Juntas of no centro are exported too, with the centro and
+	// geography empty
+	rows, err := dbmap.Db.Query(
+		`SELECT
+			personas.cedula,
+			personas.nombre,
+			personas.apellido_1,
+			personas.apellido_2,
+			personas.genero,
+			personas.expiracion,
+			padron.junta_id,
+			IFNULL(centros.tipo, ''),
+			IFNULL(centros.nombre, ''),
+			IFNULL(centros.direccion, ''),
+			IFNULL(centros.url, ''),
+			IFNULL(distritos_electorales.nombre, ''),
+			IFNULL(distritos.nombre, ''),
+			IFNULL(cantones.nombre, ''),
+			IFNULL(provincias.nombre, '')
+		FROM
+			padron
+		JOIN
+			personas ON personas.id = padron.persona_id
+		LEFT JOIN
+			juntas ON juntas.eleccion_id = padron.eleccion_id AND juntas.id = padron.junta_id
+		LEFT JOIN
+			centros ON centros.eleccion_id = juntas.eleccion_id AND centros.id = juntas.centro_id
+		LEFT JOIN
+			distritos_electorales ON distritos_electorales.id = centros.distrito_electoral_id
+		LEFT JOIN
+			distritos ON distritos.id = distritos_electorales.distrito_id
+		LEFT JOIN
+			cantones ON cantones.id = distritos.canton_id
+		LEFT JOIN
+			provincias ON provincias.id = cantones.provincia_id
+		WHERE padron.eleccion_id = ?
+		ORDER BY padron.persona_id`, e.Id)
+	if err != nil {
+		log.Fatalf(`E: Can't query padron: %s. Abort.`, err)
+	}
+	defer rows.Close()
+
+	row := make([]string, len(exportColumns))
+	dest := make([]interface{}, len(row))
+	for i := range row {
+		dest[i] = &row[i]
+	}
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			log.Fatalf(`E: Can't read padron: %s. Abort.`, err)
+		}
+		if err := w.write(row); err != nil {
+			log.Fatalf(`E: Can't write: %s. Abort.`, err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatalf(`E: Can't read padron: %s. Abort.`, err)
+	}
+	if err := w.flush(); err != nil {
+		log.Fatalf(`E: Can't write: %s. Abort.`, err)
+	}
+
+	log.Printf("I: Exported %d personas of election %s", n, e.Nombre)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"cli"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"server"
+)
+
+var cmdLookup = &cli.Command{
+	Name:      "lookup",
+	UsageLine: "cedula...",
+	Short:     "tell where personas vote",
+	Long: `
+Prints where the personas with the given cedulas vote, like bin/padron
+answers on Telegram or, with -json, at /persona/{id}.
+`,
+}
+
+var (
+	lookupEleccion = cmdLookup.Flag.String("eleccion", "",
+		"election to look in, e.g. 2018 (default: the latest one)")
+	lookupJSON = cmdLookup.Flag.Bool("json", false, "print JSON, a line per persona")
+)
+
+func init() {
+	cmdLookup.Run = runLookup
+}
+
+func runLookup(cmd *cli.Command, args []string) {
+	if len(args) == 0 {
+		cmd.Fail()
+	}
+
+	dbmap := cli.OpenDb()
+	defer dbmap.Db.Close()
+
+	failed := false
+	enc := json.NewEncoder(os.Stdout)
+	for i, cedula := range args {
+		p, err := server.FindPersona(dbmap, cedula, *lookupEleccion)
+		if err != nil {
+			log.Printf("W: %s: %s", cedula, err)
+			failed = true
+			continue
+		}
+
+		if *lookupJSON {
+			if err := enc.Encode(p); err != nil {
+				log.Fatalf(`E: Can't write: %s. Abort.`, err)
+			}
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(p.Cedula)
+		fmt.Println(server.FormatPersona(p))
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"cli"
+	"parser"
+	"tse"
+)
+
+func main() {
+	cli.Main([]*cli.Command{
+		cmdServe,
+		parser.Import,
+		tse.Scrape,
+		tse.Check,
+		cmdExport,
+		cmdLookup,
+	}, cmdServe)
+}
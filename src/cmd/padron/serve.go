@@ -0,0 +1,69 @@
+package main
+
+import (
+	"cli"
+	"fmt"
+	"log"
+	"net/http"
+	"server"
+	"time"
+)
+
+var cmdServe = &cli.Command{
+	Name:  "serve",
+	Short: "answer where personas vote, on the web and by Telegram and SMS",
+	Long: `
+Serves the database and the web page in static, on port 80 or, if it
+can't, the first free one from 8080 to 8089.
+`,
+}
+
+var serveCfg server.Config
+
+func init() {
+	cmdServe.Run = runServe
+
+	f := &cmdServe.Flag
+	f.IntVar(&serveCfg.RateLimit, "rate-limit", 0,
+		"lookups allowed per client and minute (0 means unlimited)")
+	f.StringVar(&serveCfg.TrustedProxies, "trusted-proxy", "",
+		"comma separated addresses or networks of reverse proxies whose X-Forwarded-For is trusted")
+	f.StringVar(&serveCfg.TelegramToken, "telegram-token", "",
+		"Telegram bot token, enables the /telegram webhook")
+	f.StringVar(&serveCfg.TelegramAPI, "telegram-api", "",
+		"Telegram Bot API base URL")
+	f.StringVar(&serveCfg.TelegramSecret, "telegram-secret", "",
+		"secret token expected in Telegram webhook requests")
+	f.StringVar(&serveCfg.SMSAdapter, "sms-adapter", "",
+		"SMS gateway adapter, enables the /sms webhook (generic)")
+	f.StringVar(&serveCfg.SMSSecret, "sms-secret", "",
+		"secret shared with the SMS gateway, required with -sms-adapter")
+	f.StringVar(&serveCfg.ElectionDate, "fecha-eleccion", "",
+		"election day (YYYY-MM-DD) for the calendar event of elections without a date")
+	f.StringVar(&serveCfg.PollsOpen, "apertura", "06:00",
+		"time polls open on election day")
+	f.StringVar(&serveCfg.PollsClose, "cierre", "18:00",
+		"time polls close on election day")
+	f.DurationVar(&serveCfg.Watch, "watch", 10*time.Second,
+		"how often to check the database for changes (0 disables it)")
+	f.StringVar(&serveCfg.AdminToken, "admin-token", "",
+		"token for POST /admin/reload, enables it")
+}
+
+func runServe(cmd *cli.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Fail()
+	}
+
+	serveCfg.Database = cli.Db
+	server.RegisterHandlers(serveCfg)
+	http.Handle("/", http.FileServer(http.Dir("static")))
+	log.Print("Trying port 80")
+	err := http.ListenAndServe("0.0.0.0:80", nil)
+	for port := 8080; err != nil && port < 8090; port++ {
+		log.Printf("Trying port %d", port)
+		addr := fmt.Sprintf("0.0.0.0:%d", port)
+		err = http.ListenAndServe(addr, nil)
+	}
+	log.Fatal(err)
+}
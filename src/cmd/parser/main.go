@@ -0,0 +1,10 @@
+package main
+
+import (
+	"cli"
+	"parser"
+)
+
+func main() {
+	cli.Run(parser.Import)
+}
@@ -0,0 +1,295 @@
+// Package comprobante renders the printable slips volunteers hand out
+// telling people where to vote.
+package comprobante
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"qr"
+	"strings"
+)
+
+// Slip is the information printed on a slip.
+type Slip struct {
+	Nombre    string
+	Cedula    string
+	Junta     string
+	Centro    string
+	Direccion string
+	Distrito  string
+	Canton    string
+	Provincia string
+
+	// URL is encoded in the QR code, it should point back to the
+	// consultation page.
+	URL string
+}
+
+const title = "Lugar de votación"
+
+// labelCols is the width in characters of the label column.
+const labelCols = 11
+
+// lines returns the label and value of each line of text on the slip,
+// with values wrapped at width characters.
+func (s *Slip) lines(width int) [][2]string {
+	var l [][2]string
+	add := func(label, value string) {
+		for i, v := range wrap(value, width-labelCols) {
+			if i > 0 {
+				label = ""
+			}
+			l = append(l, [2]string{label, v})
+		}
+	}
+	add("Nombre:", s.Nombre)
+	add("Cédula:", s.Cedula)
+	add("Junta:", s.Junta)
+	add("Centro:", s.Centro)
+	add("Dirección:", s.Direccion)
+	add("", strings.Join([]string{s.Distrito, s.Canton, s.Provincia}, ", "))
+	return l
+}
+
+// wrap splits s in lines of at most width characters, breaking at
+// spaces when possible.
+func wrap(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, w := range strings.Fields(s) {
+		for len([]rune(w)) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, string([]rune(w)[:width]))
+			w = string([]rune(w)[width:])
+		}
+		switch {
+		case line == "":
+			line = w
+		case len([]rune(line))+1+len([]rune(w)) <= width:
+			line += " " + w
+		default:
+			lines = append(lines, line)
+			line = w
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+const (
+	pngScale    = 3            // pixels per font dot
+	pngCell     = 6 * pngScale // character width
+	pngLine     = 11 * pngScale
+	pngMargin   = 30
+	pngTextCols = 34
+	pngQRScale  = 6
+)
+
+// WritePNG writes the slip as a PNG image.
+func WritePNG(w io.Writer, s *Slip) error {
+	code, err := qr.Encode([]byte(s.URL))
+	if err != nil {
+		return err
+	}
+	qrImg := code.Image(pngQRScale)
+	qrSize := qrImg.Bounds().Dx()
+
+	lines := s.lines(pngTextCols)
+
+	width := 2*pngMargin + pngTextCols*pngCell + qrSize
+	height := 2*pngMargin + (len(lines)+2)*pngLine
+	if h := 2*pngMargin + qrSize; h > height {
+		height = h
+	}
+
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.ZP, draw.Src)
+
+	// Border
+	for x := 0; x < width; x++ {
+		img.SetGray(x, 0, color.Gray{0})
+		img.SetGray(x, height-1, color.Gray{0})
+	}
+	for y := 0; y < height; y++ {
+		img.SetGray(0, y, color.Gray{0})
+		img.SetGray(width-1, y, color.Gray{0})
+	}
+
+	y := pngMargin
+	drawText(img, pngMargin, y, title, true)
+	y += 2 * pngLine
+	for _, l := range lines {
+		drawText(img, pngMargin, y, l[0], true)
+		drawText(img, pngMargin+labelCols*pngCell, y, l[1], false)
+		y += pngLine
+	}
+
+	qrPos := image.Pt(width-pngMargin-qrSize, pngMargin)
+	draw.Draw(img, qrImg.Bounds().Add(qrPos), qrImg, image.ZP, draw.Src)
+
+	return png.Encode(w, img)
+}
+
+// drawText draws s with its top left corner at (x, y).  bold text is
+// drawn with wider dots.
+func drawText(img *image.Gray, x, y int, s string, bold bool) {
+	for _, r := range s {
+		base, mark, flip := decompose(r)
+		if base < ' ' || base > '~' {
+			base = '?'
+		}
+		g := glyphs[base-' ']
+		for col := 0; col < 5; col++ {
+			for row := 0; row < 8; row++ {
+				c, rr := col, row
+				if flip {
+					c, rr = 4-col, 6-row
+				}
+				if rr < 0 {
+					continue
+				}
+				if g[c]&(1<<uint(rr)) != 0 {
+					dot(img, x, y, col, row+2, bold)
+				}
+			}
+			if mark != nil {
+				for row := 0; row < 2; row++ {
+					if mark[col]&(1<<uint(row)) != 0 {
+						dot(img, x, y, col, row, bold)
+					}
+				}
+			}
+		}
+		x += pngCell
+	}
+}
+
+func dot(img *image.Gray, x, y, col, row int, bold bool) {
+	n := pngScale
+	if bold {
+		n++
+	}
+	for dy := 0; dy < pngScale; dy++ {
+		for dx := 0; dx < n; dx++ {
+			img.SetGray(x+col*pngScale+dx, y+row*pngScale+dy, color.Gray{0})
+		}
+	}
+}
+
+// PDF page size, A6 landscape in points.
+const (
+	pdfWidth    = 420
+	pdfHeight   = 298
+	pdfMargin   = 24
+	pdfQRSize   = 120
+	pdfFontSize = 10
+	pdfLeading  = 14
+	pdfTextCols = 40
+	pdfLabel    = 60 // width of the label column
+)
+
+// WritePDF writes the slip as a single page PDF document using the
+// standard Helvetica fonts, so nothing needs to be embedded.
+func WritePDF(w io.Writer, s *Slip) error {
+	code, err := qr.Encode([]byte(s.URL))
+	if err != nil {
+		return err
+	}
+
+	var c bytes.Buffer
+
+	// Border
+	fmt.Fprintf(&c, "0.5 w 4 4 %d %d re S\n", pdfWidth-8, pdfHeight-8)
+
+	y := pdfHeight - pdfMargin - 14
+	fmt.Fprintf(&c, "BT /F2 14 Tf %d %d Td (%s) Tj ET\n",
+		pdfMargin, y, pdfString(title))
+	y -= 2 * pdfLeading
+
+	for _, l := range s.lines(pdfTextCols) {
+		fmt.Fprintf(&c, "BT /F2 %d Tf %d %d Td (%s) Tj ET\n",
+			pdfFontSize, pdfMargin, y, pdfString(l[0]))
+		fmt.Fprintf(&c, "BT /F1 %d Tf %d %d Td (%s) Tj ET\n",
+			pdfFontSize, pdfMargin+pdfLabel, y, pdfString(l[1]))
+		y -= pdfLeading
+	}
+
+	// QR code, one filled square per dark module
+	n := code.Size + 8
+	m := float64(pdfQRSize) / float64(n)
+	x0 := float64(pdfWidth - pdfMargin - pdfQRSize)
+	y0 := float64(pdfHeight - pdfMargin - pdfQRSize)
+	for yy := 0; yy < code.Size; yy++ {
+		for xx := 0; xx < code.Size; xx++ {
+			if code.Black(xx, yy) {
+				fmt.Fprintf(&c, "%.3f %.3f %.3f %.3f re\n",
+					x0+float64(xx+4)*m,
+					y0+float64(n-5-yy)*m, m, m)
+			}
+		}
+	}
+	c.WriteString("f\n")
+
+	fmt.Fprintf(&c, "BT /F1 7 Tf %d %d Td (%s) Tj ET\n",
+		pdfMargin, pdfMargin-10, pdfString(s.URL))
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> "+
+			"/Contents 6 0 R >>", pdfWidth, pdfHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica " +
+			"/Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold " +
+			"/Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", c.Len(), c.String()),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, o := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1, xref)
+
+	_, err = out.WriteTo(w)
+	return err
+}
+
+// pdfString encodes s as the contents of a PDF literal string in
+// WinAnsiEncoding, which matches Latin-1 for the letters used in
+// Spanish.
+func pdfString(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r >= ' ' && r <= '~' || r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// BaselineEleccion is the election the padron of a database built
+// before the elecciones is assigned to.
+const BaselineEleccion = "anterior"
+
+func hasColumn(tx queryRower, table, name string) (bool, error) {
+	n := 0
+	err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?)
+		WHERE name = ?`, table, name).Scan(&n)
+	return n > 0, err
+}
+
+// adoptBaseline brings a database built from schema.sql before the
+// elecciones, and before the schema versions, to the tables of
+// migration 1: its centros, juntas and padron are rebuilt with the
+// eleccion_id of BaselineEleccion, and the tables it lacks created.
+func adoptBaseline(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The new padron indexes have the names of the old ones
+	_, err = tx.Exec(`
+		DROP INDEX IF EXISTS idx_padron_persona_id;
+		DROP INDEX IF EXISTS idx_padron_junta_id;
+		ALTER TABLE centros RENAME TO centros_baseline;
+		ALTER TABLE juntas RENAME TO juntas_baseline;
+		ALTER TABLE padron RENAME TO padron_baseline`)
+	if err != nil {
+		return err
+	}
+
+	ok, err := hasTable(tx, "importaciones")
+	if err != nil {
+		return err
+	}
+	if ok {
+		ok, err = hasColumn(tx, "importaciones", "eleccion_id")
+		if err == nil && !ok {
+			_, err = tx.Exec(`ALTER TABLE importaciones
+				ADD COLUMN eleccion_id INTEGER NOT NULL DEFAULT 1 REFERENCES elecciones(id)`)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// The rest of the tables are as migration 1 left them
+	initial := strings.NewReplacer(
+		"CREATE TABLE ", "CREATE TABLE IF NOT EXISTS ",
+		"CREATE INDEX ", "CREATE INDEX IF NOT EXISTS ",
+		"CREATE UNIQUE INDEX ", "CREATE UNIQUE INDEX IF NOT EXISTS ",
+	).Replace(migrations[0].sql)
+	if _, err := tx.Exec(initial); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec("INSERT INTO elecciones (nombre) VALUES (?)", BaselineEleccion)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	// Columns added to schema.sql before the elecciones
+	tipo, err := hasColumn(tx, "centros_baseline", "tipo")
+	if err != nil {
+		return err
+	}
+	electores, err := hasColumn(tx, "juntas_baseline", "electores")
+	if err != nil {
+		return err
+	}
+	tipoCol, electoresCol := "''", "0"
+	if tipo {
+		tipoCol = "tipo"
+	}
+	if electores {
+		electoresCol = "electores"
+	}
+
+	for _, stmt := range []string{
+		`INSERT INTO centros
+			(eleccion_id, id, distrito_electoral_id, tipo, nombre, direccion, url)
+			SELECT ?, id, distrito_electoral_id, ` + tipoCol + `, nombre, direccion, url
+			FROM centros_baseline`,
+		`INSERT INTO juntas (eleccion_id, id, centro_id, electores)
+			SELECT ?, id, centro_id, ` + electoresCol + ` FROM juntas_baseline`,
+		`INSERT INTO padron (eleccion_id, persona_id, junta_id)
+			SELECT ?, persona_id, junta_id FROM padron_baseline`,
+		`UPDATE importaciones SET eleccion_id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		DROP TABLE padron_baseline;
+		DROP TABLE juntas_baseline;
+		DROP TABLE centros_baseline`)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
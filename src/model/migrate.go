@@ -0,0 +1,140 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is a numbered change to the schema.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// SchemaVersion is the version of the schema this program works with.
+var SchemaVersion = migrations[len(migrations)-1].version
+
+// queryRower is a *sql.DB or a *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func hasTable(db queryRower, name string) (bool, error) {
+	n := 0
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name = ?`, name).Scan(&n)
+	return n > 0, err
+}
+
+// Version returns the schema version of db, zero for an empty one or
+// one built from schema.sql before the elecciones.
+func Version(db *sql.DB) (int, error) {
+	ok, err := hasTable(db, "schema_version")
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		v := 0
+		err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&v)
+		return v, err
+	}
+
+	// Databases built from schema.sql, before the versions, have the
+	// tables of the first one, if they have the elecciones
+	if ok, err := hasTable(db, "elecciones"); err != nil || ok {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// CheckSchema fails unless db has the schema this program works with.
+func CheckSchema(db *sql.DB) error {
+	v, err := Version(db)
+	switch {
+	case err != nil:
+		return err
+	case v > SchemaVersion:
+		return fmt.Errorf("schema version %d is newer than this program's %d, update it",
+			v, SchemaVersion)
+	case v < SchemaVersion:
+		return fmt.Errorf("schema version %d is older than this program's %d, run migrate",
+			v, SchemaVersion)
+	}
+	return nil
+}
+
+// Migrate brings db to SchemaVersion, applying each missing migration
+// in its own transaction.  It returns the version db had.
+func Migrate(db *sql.DB) (int, error) {
+	from, err := Version(db)
+	if err != nil {
+		return 0, err
+	}
+	if from > SchemaVersion {
+		return from, CheckSchema(db)
+	}
+
+	versioned, err := hasTable(db, "schema_version")
+	if err != nil {
+		return from, err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		nombre TEXT NOT NULL,
+		fecha INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return from, err
+	}
+
+	at := from
+	if from == 0 {
+		ok, err := hasTable(db, "personas")
+		if err != nil {
+			return from, err
+		}
+		if ok {
+			if err := adoptBaseline(db); err != nil {
+				return from, fmt.Errorf("database before the elecciones: %s", err)
+			}
+			at = 1
+		}
+	}
+
+	for _, m := range migrations {
+		if m.version < at || (m.version == at && versioned) {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return from, err
+		}
+		// A database adopted at its version already has its tables
+		if m.version > at {
+			if _, err := tx.Exec(m.sql); err != nil {
+				tx.Rollback()
+				return from, fmt.Errorf("migration %d %s: %s", m.version, m.name, err)
+			}
+		}
+		_, err = tx.Exec(`INSERT OR IGNORE INTO schema_version (version, nombre, fecha)
+			VALUES (?, ?, ?)`, m.version, m.name, time.Now().Unix())
+		if err != nil {
+			tx.Rollback()
+			return from, err
+		}
+		if err := tx.Commit(); err != nil {
+			return from, err
+		}
+	}
+
+	return from, nil
+}
+
+// Open opens the database at path without checking its schema, for
+// Migrate.
+func Open(path string) (*sql.DB, error) {
+	return sql.Open("sqlite3", path)
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Databases built from schema.sql before the elecciones, by the
+// baseline and by the last version without them
+var oldSchemas = []string{
+	"schema-baseline.sql",
+	"schema-importaciones.sql",
+}
+
+const oldRows = `
+	INSERT INTO provincias VALUES (1, 'SAN JOSE');
+	INSERT INTO cantones VALUES (101, 1, 'CENTRAL');
+	INSERT INTO distritos VALUES (101001, 101, 'CARMEN');
+	INSERT INTO distritos_electorales VALUES (101001001, 101001, 'CARMEN');
+	INSERT INTO centros (id, distrito_electoral_id, nombre, direccion, url)
+		VALUES (7, 101001001, 'ESCUELA REPUBLICA DE MEXICO', '', '');
+	INSERT INTO juntas (id, centro_id) VALUES (1, 7);
+	INSERT INTO personas VALUES (101110111, '101110111', 20251231,
+		'JUAN', 'RODRIGUEZ', 'MORA', 1);
+	INSERT INTO padron VALUES (101110111, 1)`
+
+func TestMigrateOldSchema(t *testing.T) {
+	for _, schema := range oldSchemas {
+		t.Run(schema, func(t *testing.T) {
+			testMigrateOld(t, schema)
+		})
+	}
+}
+
+func testMigrateOld(t *testing.T, schema string) {
+	sql, err := ioutil.ReadFile(filepath.Join("testdata", schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp, err := ioutil.TempDir("", "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	db, err := Open(filepath.Join(tmp, "padron.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(string(sql)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(oldRows); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := Version(db); err != nil || v != 0 {
+		t.Fatalf("Version = %d, %v before migrating, want 0", v, err)
+	}
+	if from, err := Migrate(db); err != nil || from != 0 {
+		t.Fatalf("Migrate = %d, %v, want 0", from, err)
+	}
+	if err := CheckSchema(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var nombre string
+	var centro, junta int64
+	err = db.QueryRow(`SELECT e.nombre, j.centro_id, p.junta_id
+		FROM padron p
+		JOIN elecciones e ON e.id = p.eleccion_id
+		JOIN juntas j ON j.eleccion_id = p.eleccion_id AND j.id = p.junta_id
+		JOIN centros c ON c.eleccion_id = j.eleccion_id AND c.id = j.centro_id
+		WHERE p.persona_id = 101110111`).Scan(&nombre, &centro, &junta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nombre != BaselineEleccion || centro != 7 || junta != 1 {
+		t.Errorf("got election %q, centro %d, junta %d, want %q, 7, 1",
+			nombre, centro, junta, BaselineEleccion)
+	}
+}
@@ -0,0 +1,156 @@
+package model
+
+// Migrations, in order.  Never change one that was released, add a new
+// one instead.
+var migrations = []migration{
+	{1, "initial", `
+	CREATE TABLE provincias (
+		id INTEGER PRIMARY KEY,
+		nombre TEXT NOT NULL
+	);
+	CREATE UNIQUE INDEX idx_provincias_nombre
+		ON provincias(nombre);
+
+	CREATE TABLE cantones (
+		id INTEGER PRIMARY KEY,
+		provincia_id INTEGER NOT NULL REFERENCES provincias(id),
+		nombre TEXT NOT NULL
+	);
+	CREATE INDEX idx_cantones_nombre
+		ON cantones(nombre);
+
+	CREATE TABLE distritos (
+		id INTEGER PRIMARY KEY,
+		canton_id INTEGER NOT NULL REFERENCES cantones(id),
+		nombre TEXT NOT NULL
+	);
+	CREATE INDEX idx_distritos_nombre
+		ON distritos(nombre);
+
+	CREATE TABLE distritos_electorales (
+		id INTEGER PRIMARY KEY,
+		distrito_id INTEGER NOT NULL REFERENCES distritos(id),
+		nombre TEXT NOT NULL
+	);
+	CREATE INDEX idx_distritos_electorales_nombre
+		ON distritos_electorales(nombre);
+
+	CREATE TABLE elecciones (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		nombre TEXT NOT NULL UNIQUE,
+		fecha TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE centros (
+		eleccion_id INTEGER NOT NULL REFERENCES elecciones(id),
+		id INTEGER NOT NULL,
+		distrito_electoral_id INTEGER NOT NULL REFERENCES distritos_electorales(id),
+		tipo TEXT NOT NULL DEFAULT '',
+		nombre TEXT NOT NULL,
+		direccion TEXT NOT NULL,
+		url TEXT NOT NULL,
+		PRIMARY KEY(eleccion_id, id),
+		UNIQUE(eleccion_id, distrito_electoral_id, nombre, direccion)
+	);
+
+	CREATE TABLE centros_ids (
+		anterior INTEGER PRIMARY KEY,
+		nuevo INTEGER NOT NULL
+	);
+
+	CREATE TABLE juntas (
+		eleccion_id INTEGER NOT NULL REFERENCES elecciones(id),
+		id INTEGER NOT NULL,
+		centro_id INTEGER NOT NULL,
+		electores INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY(eleccion_id, id),
+		FOREIGN KEY(eleccion_id, centro_id) REFERENCES centros(eleccion_id, id)
+	);
+
+	CREATE TABLE personas (
+		id INTEGER PRIMARY KEY,
+		cedula TEXT NOT NULL,
+		expiracion INTEGER NOT NULL,
+		nombre TEXT NOT NULL,
+		apellido_1 TEXT NOT NULL,
+		apellido_2 TEXT NOT NULL,
+		genero INTEGER NOT NULL
+	);
+	CREATE UNIQUE INDEX idx_personas_cedula
+		ON personas(cedula);
+
+	CREATE TABLE padron (
+		eleccion_id INTEGER NOT NULL REFERENCES elecciones(id),
+		persona_id INTEGER NOT NULL REFERENCES personas(id),
+		junta_id INTEGER NOT NULL,
+		UNIQUE(eleccion_id, persona_id, junta_id)
+	);
+	CREATE UNIQUE INDEX idx_padron_persona_id
+		ON padron(eleccion_id, persona_id);
+	CREATE INDEX idx_padron_junta_id
+		ON padron(eleccion_id, junta_id);
+	CREATE INDEX idx_padron_persona
+		ON padron(persona_id);
+
+	CREATE TABLE importaciones (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		eleccion_id INTEGER NOT NULL REFERENCES elecciones(id),
+		fecha INTEGER NOT NULL,
+		archivos TEXT NOT NULL,
+		personas INTEGER NOT NULL,
+		errores INTEGER NOT NULL,
+		advertencias INTEGER NOT NULL
+	);
+
+	CREATE TABLE cambios (
+		importacion_id INTEGER NOT NULL REFERENCES importaciones(id),
+		persona_id INTEGER NOT NULL,
+		tipo TEXT NOT NULL,
+		junta_anterior INTEGER,
+		junta_nueva INTEGER,
+		UNIQUE(importacion_id, persona_id, tipo)
+	);
+	CREATE INDEX idx_cambios_persona_id
+		ON cambios(persona_id);
+`},
+	{2, "progreso", `
+	ALTER TABLE importaciones
+		ADD COLUMN terminada INTEGER NOT NULL DEFAULT 1;
+
+	CREATE TABLE progreso (
+		importacion_id INTEGER NOT NULL REFERENCES importaciones(id),
+		archivo TEXT NOT NULL,
+		sha256 TEXT NOT NULL,
+		lineas INTEGER NOT NULL,
+		filas INTEGER NOT NULL,
+		terminado INTEGER NOT NULL,
+		fecha INTEGER NOT NULL,
+		PRIMARY KEY (importacion_id, archivo)
+	);
+`},
+	// Centros are per election, and so are their old ids.  Those
+	// recorded before go to the elections with the new id, or the
+	// latest one.
+	{3, "centros_ids por eleccion", `
+	ALTER TABLE centros_ids RENAME TO centros_ids_sin_eleccion;
+
+	CREATE TABLE centros_ids (
+		eleccion_id INTEGER NOT NULL REFERENCES elecciones(id),
+		anterior INTEGER NOT NULL,
+		nuevo INTEGER NOT NULL,
+		PRIMARY KEY (eleccion_id, anterior)
+	);
+
+	INSERT OR IGNORE INTO centros_ids (eleccion_id, anterior, nuevo)
+		SELECT c.eleccion_id, ci.anterior, ci.nuevo
+		FROM centros_ids_sin_eleccion ci
+		JOIN centros c ON c.id = ci.nuevo;
+	INSERT OR IGNORE INTO centros_ids (eleccion_id, anterior, nuevo)
+		SELECT (SELECT MAX(id) FROM elecciones), anterior, nuevo
+		FROM centros_ids_sin_eleccion
+		WHERE nuevo NOT IN (SELECT id FROM centros)
+			AND EXISTS (SELECT 1 FROM elecciones);
+
+	DROP TABLE centros_ids_sin_eleccion;
+`},
+}
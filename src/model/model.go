@@ -2,6 +2,7 @@ package model
 
 import (
 	"database/sql"
+	"fmt"
 
 	"github.com/coopernurse/gorp"
 	_ "github.com/mattn/go-sqlite3"
@@ -40,7 +41,16 @@ type DistritoElectoral struct {
 	DistritoId int64  `db:"distrito_id"`
 }
 
+// Eleccion is an election, each one has its own centros, juntas and
+// padron.  Nombre is how users refer to it, e.g. 2018.
+type Eleccion struct {
+	Id     int64  `db:"id"`
+	Nombre string `db:"nombre"`
+	Fecha  string `db:"fecha"` // YYYY-MM-DD
+}
+
 type Centro struct {
+	EleccionId          int64  `db:"eleccion_id"`
 	Id                  int64  `db:"id"`
 	Tipo                string `db:"tipo"`
 	Nombre              string `db:"nombre"`
@@ -50,38 +60,132 @@ type Centro struct {
 }
 
 type Junta struct {
-	Id       int64 `db:"id"`
-	CentroId int64 `db:"centro_id"`
+	EleccionId int64 `db:"eleccion_id"`
+	Id         int64 `db:"id"`
+	CentroId   int64 `db:"centro_id"`
+	Electores  int64 `db:"electores"`
+}
+
+// CentroId records that the centro of an election known as Anterior
+// is now Nuevo.
+type CentroId struct {
+	EleccionId int64 `db:"eleccion_id"`
+	Anterior   int64 `db:"anterior"`
+	Nuevo      int64 `db:"nuevo"`
 }
 
 type ItemPadron struct {
-	PersonaId int64 `db:"persona_id"`
-	JuntaId   int64 `db:"junta_id"`
+	EleccionId int64 `db:"eleccion_id"`
+	PersonaId  int64 `db:"persona_id"`
+	JuntaId    int64 `db:"junta_id"`
 }
 
+// Importacion records where the data of a database came from.
+type Importacion struct {
+	Id           int64  `db:"id"`
+	EleccionId   int64  `db:"eleccion_id"`
+	Fecha        int64  `db:"fecha"`    // Unix time
+	Archivos     string `db:"archivos"` // JSON: file, kind, encoding, sha256
+	Personas     int64  `db:"personas"`
+	Errores      int64  `db:"errores"`
+	Advertencias int64  `db:"advertencias"`
+	Terminada    bool   `db:"terminada"` // false while it's being loaded
+}
+
+// Progreso records how far an import got through a padron file, so
+// that an interrupted one can be resumed.
+type Progreso struct {
+	ImportacionId int64  `db:"importacion_id"`
+	Archivo       string `db:"archivo"`
+	Sha256        string `db:"sha256"`
+	Lineas        int64  `db:"lineas"` // lines loaded and committed
+	Filas         int64  `db:"filas"`  // rows inserted from them
+	Terminado     bool   `db:"terminado"`
+	Fecha         int64  `db:"fecha"` // Unix time of the last checkpoint
+}
+
+// Cambio records a change to the padron applied by an incremental
+// import.
+type Cambio struct {
+	ImportacionId int64         `db:"importacion_id"`
+	PersonaId     int64         `db:"persona_id"`
+	Tipo          string        `db:"tipo"`
+	JuntaAnterior sql.NullInt64 `db:"junta_anterior"`
+	JuntaNueva    sql.NullInt64 `db:"junta_nueva"`
+}
+
+// Kinds of Cambio
+const (
+	CambioAlta       = "alta"       // added to the padron
+	CambioBaja       = "baja"       // removed from the padron
+	CambioTraslado   = "traslado"   // moved to another junta
+	CambioCorreccion = "correccion" // name, expiracion or genero changed
+)
+
+// InitDb opens padron.db in the current directory.
 func InitDb() (*gorp.DbMap, error) {
-	db, err := sql.Open("sqlite3", "padron.db")
+	return OpenDb("padron.db")
+}
+
+// OpenDb opens the database at path, which must have the schema of
+// this program, see Migrate.
+func OpenDb(path string) (*gorp.DbMap, error) {
+	db, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return nil, err
 	}
+	dbmap, err := NewDbMap(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return dbmap, nil
+}
+
+// NewDbMap maps the tables of db, which must have the schema of this
+// program.
+func NewDbMap(db *sql.DB) (*gorp.DbMap, error) {
+	if err := CheckSchema(db); err != nil {
+		return nil, err
+	}
 	dbmap := &gorp.DbMap{Db: db, Dialect: gorp.SqliteDialect{}}
 
 	dbmap.AddTableWithName(Persona{}, "personas").SetKeys(true, "Id")
-	dbmap.AddTableWithName(Junta{}, "juntas").SetKeys(false, "Id")
-	dbmap.AddTableWithName(Centro{}, "centros").SetKeys(true, "Id")
+	dbmap.AddTableWithName(Eleccion{}, "elecciones").SetKeys(true, "Id")
+	dbmap.AddTableWithName(Junta{}, "juntas").SetKeys(false, "EleccionId", "Id")
+	dbmap.AddTableWithName(Centro{}, "centros").SetKeys(false, "EleccionId", "Id")
 	dbmap.AddTableWithName(Distrito{}, "distritos").SetKeys(false, "Id")
 	dbmap.AddTableWithName(DistritoElectoral{}, "distritos_electorales").
 		SetKeys(false, "Id")
 	dbmap.AddTableWithName(Canton{}, "cantones").SetKeys(false, "Id")
 	dbmap.AddTableWithName(Provincia{}, "provincias").SetKeys(false, "Id")
+	dbmap.AddTableWithName(CentroId{}, "centros_ids").SetKeys(false, "EleccionId", "Anterior")
+	dbmap.AddTableWithName(Importacion{}, "importaciones").SetKeys(true, "Id")
+	dbmap.AddTableWithName(Progreso{}, "progreso").
+		SetKeys(false, "ImportacionId", "Archivo")
+	dbmap.AddTableWithName(Cambio{}, "cambios").
+		SetKeys(false, "ImportacionId", "PersonaId", "Tipo")
 	dbmap.AddTableWithName(ItemPadron{}, "padron").
-		SetKeys(false, "PersonaId", "JuntaId")
+		SetKeys(false, "EleccionId", "PersonaId")
+
+	return dbmap, nil
+}
+
+// FindEleccion returns the election called nombre or, if nombre is
+// empty, the latest one.  It returns sql.ErrNoRows if there is none.
+func FindEleccion(db gorp.SqlExecutor, nombre string) (*Eleccion, error) {
+	var e Eleccion
+	var err error
 
-	err = dbmap.CreateTablesIfNotExists()
+	if nombre == "" {
+		err = db.SelectOne(&e, `SELECT * FROM elecciones
+			ORDER BY fecha DESC, id DESC LIMIT 1`)
+	} else {
+		err = db.SelectOne(&e, `SELECT * FROM elecciones WHERE nombre = ?`, nombre)
+	}
 	if err != nil {
-		dbmap.Db.Close()
 		return nil, err
 	}
 
-	return dbmap, err
+	return &e, nil
 }
@@ -0,0 +1,95 @@
+BEGIN TRANSACTION;
+	CREATE TABLE IF NOT EXISTS provincias (
+		id INTEGER PRIMARY KEY,
+		nombre TEXT NOT NULL
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_provincias_nombre
+		ON provincias(nombre);
+
+	CREATE TABLE IF NOT EXISTS cantones (
+		id INTEGER PRIMARY KEY,
+		provincia_id INTEGER NOT NULL REFERENCES provincias(id),
+		nombre TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_cantones_nombre
+		ON cantones(nombre);
+
+	CREATE TABLE IF NOT EXISTS distritos (
+		id INTEGER PRIMARY KEY,
+		canton_id INTEGER NOT NULL REFERENCES cantones(id),
+		nombre TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_distritos_nombre
+		ON distritos(nombre);
+
+	CREATE TABLE IF NOT EXISTS distritos_electorales (
+		id INTEGER PRIMARY KEY,
+		distrito_id INTEGER NOT NULL REFERENCES distritos(id),
+		nombre TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_distritos_electorales_nombre
+		ON distritos_electorales(nombre);
+
+	CREATE TABLE IF NOT EXISTS centros (
+		id INTEGER PRIMARY KEY,
+		distrito_electoral_id INTEGER NOT NULL REFERENCES distritos_electorales(id),
+		tipo TEXT NOT NULL DEFAULT '',
+		nombre TEXT NOT NULL,
+		direccion TEXT NOT NULL,
+		url TEXT NOT NULL,
+		UNIQUE(distrito_electoral_id, nombre, direccion)
+	);
+
+	CREATE TABLE IF NOT EXISTS centros_ids (
+		anterior INTEGER PRIMARY KEY,
+		nuevo INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS juntas (
+		id INTEGER PRIMARY KEY,
+		centro_id INTEGER NOT NULL REFERENCES centros(id),
+		electores INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS personas (
+		id INTEGER PRIMARY KEY,
+		cedula TEXT NOT NULL,
+		expiracion INTEGER NOT NULL,
+		nombre TEXT NOT NULL,
+		apellido_1 TEXT NOT NULL,
+		apellido_2 TEXT NOT NULL,
+		genero INTEGER NOT NULL
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_personas_cedula
+		ON personas(cedula);
+
+	CREATE TABLE IF NOT EXISTS padron (
+		persona_id INTEGER NOT NULL REFERENCES personas(id),
+		junta_id INTEGER NOT NULL REFERENCES juntas(id),
+		UNIQUE(persona_id, junta_id)
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_padron_persona_id
+		ON padron(persona_id);
+	CREATE INDEX IF NOT EXISTS idx_padron_junta_id
+		ON padron(junta_id);
+
+	CREATE TABLE IF NOT EXISTS importaciones (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		fecha INTEGER NOT NULL,
+		archivos TEXT NOT NULL,
+		personas INTEGER NOT NULL,
+		errores INTEGER NOT NULL,
+		advertencias INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS cambios (
+		importacion_id INTEGER NOT NULL REFERENCES importaciones(id),
+		persona_id INTEGER NOT NULL,
+		tipo TEXT NOT NULL,
+		junta_anterior INTEGER,
+		junta_nueva INTEGER,
+		UNIQUE(importacion_id, persona_id, tipo)
+	);
+	CREATE INDEX IF NOT EXISTS idx_cambios_persona_id
+		ON cambios(persona_id);
+COMMIT TRANSACTION;
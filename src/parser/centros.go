@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"hash/fnv"
+	"log"
+	"model"
+	"strconv"
+
+	"github.com/coopernurse/gorp"
+)
+
+// centroId derives the id of a centro from its distrito electoral and
+// its name, so that it survives re-publications of the centros list
+// where rows are added, removed or reordered.  Ids fit in 53 bits to
+// stay exact in JavaScript.
+func centroId(deId, tipo, nombre string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(deId + "|" + foldName(tipo+" "+nombre)))
+	return int64(h.Sum64() & (1<<53 - 1))
+}
+
+// juntaRange is the first and last junta of a centro in a distrito
+// electoral.
+type juntaRange struct {
+	de, first, last int64
+}
+
+// add extends r to junta.
+func (r *juntaRange) add(de, junta int64) {
+	if r.de == 0 || junta < r.first {
+		r.first = junta
+	}
+	if r.de == 0 || junta > r.last {
+		r.last = junta
+	}
+	r.de = de
+}
+
+// replaceCentros moves the centros of the election eleccionId already
+// in the database under ids other than the ones derived by centroId to
+// the new centros, by id in ranges: those assigned by older versions
+// of the parser to the derived id, renamed ones to the centro with the
+// same juntas.  The scraped direccion and url are kept, the juntas
+// follow and the change is recorded in centros_ids.
+func replaceCentros(trans *gorp.Transaction, eleccionId int64,
+	centros map[int64]*model.Centro, ranges map[int64]juntaRange) error {
+
+	var old []model.Centro
+
+	_, err := trans.Select(&old, `SELECT * FROM centros WHERE eleccion_id = ?`, eleccionId)
+	if err != nil {
+		return err
+	}
+
+	var oldRanges []struct {
+		CentroId  int64
+		DE, First int64
+		Last      int64
+	}
+	_, err = trans.Select(&oldRanges, `SELECT
+			j.centro_id AS CentroId,
+			c.distrito_electoral_id AS DE,
+			MIN(j.id) AS First,
+			MAX(j.id) AS Last
+		FROM juntas j
+		JOIN centros c ON c.eleccion_id = j.eleccion_id AND c.id = j.centro_id
+		WHERE j.eleccion_id = ?
+		GROUP BY j.centro_id`, eleccionId)
+	if err != nil {
+		return err
+	}
+	had := make(map[int64]juntaRange)
+	for _, r := range oldRanges {
+		had[r.CentroId] = juntaRange{r.DE, r.First, r.Last}
+	}
+	byRange := make(map[juntaRange]int64)
+	for id, r := range ranges {
+		byRange[r] = id
+	}
+
+	moved := 0
+	for _, o := range old {
+		if _, ok := centros[o.Id]; ok {
+			continue
+		}
+
+		id := centroId(strconv.FormatInt(o.DistritoElectoralId, 10), o.Tipo, o.Nombre)
+		c, ok := centros[id]
+		if !ok {
+			if r, ok := had[o.Id]; ok {
+				id, ok = byRange[r]
+				c = centros[id]
+			}
+			if c == nil {
+				continue
+			}
+		}
+
+		if c.Direccion == "" && c.Url == "" {
+			c.Direccion, c.Url = o.Direccion, o.Url
+		}
+
+		if _, err := trans.Exec("DELETE FROM centros WHERE eleccion_id = ? AND id = ?",
+			eleccionId, o.Id); err != nil {
+			return err
+		}
+		if _, err := trans.Exec(`UPDATE juntas SET centro_id = ?
+			WHERE eleccion_id = ? AND centro_id = ?`, id, eleccionId, o.Id); err != nil {
+			return err
+		}
+		if err := trans.Insert(&model.CentroId{EleccionId: eleccionId, Anterior: o.Id, Nuevo: id}); err != nil {
+			return err
+		}
+		moved++
+	}
+
+	if moved > 0 {
+		log.Printf("I: %d centros got a new id, see centros_ids", moved)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"model"
+	"os"
+
+	"github.com/coopernurse/gorp"
+)
+
+// The padron load commits a checkpoint every checkpointRows rows,
+// recording in progreso how many lines of each file are loaded.  When
+// the parser is run again with the same inputs, an interrupted import
+// in the database being built is resumed from there instead of started
+// over.  The import is marked terminada once loaded.
+
+// resumable returns the interrupted import in the database being built
+// at tmp, if it can be resumed: it must have the same input files,
+// according to diags, be into the election called eleccion, if not
+// empty, and be incremental if incremental is set.  It returns nil and
+// the reason if it can't, nil and nil if there is nothing to resume.
+func resumable(tmp, eleccion string, incremental bool) (*model.Importacion, error) {
+	if _, err := os.Stat(tmp); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	dbmap, err := model.OpenDb(tmp)
+	if err != nil {
+		return nil, err
+	}
+	defer dbmap.Db.Close()
+
+	// A crash of the machine may have left it broken
+	ok, err := dbmap.SelectStr("PRAGMA quick_check")
+	if err != nil {
+		return nil, err
+	}
+	if ok != "ok" {
+		return nil, fmt.Errorf("quick check failed: %s", ok)
+	}
+
+	var imps []model.Importacion
+	_, err = dbmap.Select(&imps, `SELECT * FROM importaciones
+		WHERE terminada = 0 ORDER BY id DESC LIMIT 1`)
+	if err != nil {
+		return nil, err
+	}
+	if len(imps) == 0 {
+		return nil, nil
+	}
+	imp := &imps[0]
+
+	if eleccion != "" {
+		e, err := model.FindEleccion(dbmap, eleccion)
+		if err != nil || e.Id != imp.EleccionId {
+			return nil, errors.New("it was into another election")
+		}
+	}
+
+	staging, err := dbmap.SelectInt(`SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name = 'nuevo_padron'`)
+	if err != nil {
+		return nil, err
+	}
+	if (staging > 0) != incremental {
+		return nil, errors.New("it was another kind of import")
+	}
+
+	var archivos []reportInput
+	if err := json.Unmarshal([]byte(imp.Archivos), &archivos); err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string)
+	for _, a := range archivos {
+		sums[a.File] = a.Sha256
+	}
+	diags.mu.Lock()
+	same := len(sums) == len(diags.Inputs)
+	for _, in := range diags.Inputs {
+		if sum, ok := sums[in.File]; !ok || sum != in.Sha256 {
+			same = false
+		}
+	}
+	diags.mu.Unlock()
+	if !same {
+		return nil, errors.New("it had other input files")
+	}
+
+	return imp, nil
+}
+
+// getProgreso returns the progress of the import imp through in, whose
+// file has the SHA-256 sum, adding it if it's new.
+func getProgreso(trans *gorp.Transaction, imp *model.Importacion, in input,
+	sum string) (*model.Progreso, error) {
+
+	obj, err := trans.Get(model.Progreso{}, imp.Id, in.String())
+	if err != nil {
+		return nil, err
+	}
+	if obj != nil {
+		p := obj.(*model.Progreso)
+		if p.Sha256 != sum {
+			return nil, errors.New("the file changed")
+		}
+		return p, nil
+	}
+
+	p := &model.Progreso{
+		ImportacionId: imp.Id,
+		Archivo:       in.String(),
+		Sha256:        sum,
+	}
+	return p, trans.Insert(p)
+}
@@ -0,0 +1,235 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Fields of the centros list.  Codigo, provincia and canton are only
+// informative and total is used to validate the range when present.
+const (
+	fieldCodigo    = "codigo"
+	fieldProvincia = "provincia"
+	fieldCanton    = "canton"
+	fieldDistrito  = "distrito"
+	fieldDE        = "distrito_electoral"
+	fieldInicial   = "inicial"
+	fieldFinal     = "final"
+	fieldTotal     = "total"
+	fieldTipo      = "tipo"
+	fieldNombre    = "nombre"
+	fieldJunta     = "junta"
+	fieldElectores = "electores"
+)
+
+var (
+	centrosRequired = []string{fieldDE, fieldInicial, fieldFinal, fieldNombre}
+	juntasRequired  = []string{fieldProvincia, fieldCanton, fieldDistrito,
+		fieldDE, fieldJunta, fieldElectores}
+)
+
+// headerRows is how far into a sheet the header row is looked for,
+// the TSE puts a few title rows above it.
+const headerRows = 20
+
+// layout maps each field to the header texts used for it in the
+// spreadsheets of one election.  Header texts are compared ignoring
+// case, accents, punctuation and spacing.
+type layout struct {
+	Centros map[string][]string `json:"centros"`
+	Juntas  map[string][]string `json:"juntas"`
+}
+
+// layouts are the known header texts by election year.  More can be
+// given in a JSON file with the same structure, see loadLayout.
+var layouts = map[string]*layout{
+	// Municipales 2016 and before
+	"2016": {
+		Centros: map[string][]string{
+			fieldCodigo:    {"CODIGO ELECTORAL", "CODELE"},
+			fieldProvincia: {"PROVINCIA"},
+			fieldCanton:    {"CANTON"},
+			fieldDE:        {"DISTRITO ELECTORAL", "NOMBRE DISTRITO ELECTORAL"},
+			fieldInicial:   {"JUNTA INICIAL", "DESDE"},
+			fieldFinal:     {"JUNTA FINAL", "HASTA"},
+			fieldTotal:     {"CANTIDAD DE JUNTAS", "TOTAL DE JUNTAS"},
+			fieldTipo:      {"TIPO DE CENTRO"},
+			fieldNombre:    {"CENTRO DE VOTACION", "NOMBRE DEL CENTRO"},
+		},
+		Juntas: map[string][]string{
+			fieldProvincia: {"PROVINCIA"},
+			fieldCanton:    {"CANTON"},
+			fieldDistrito:  {"DISTRITO ADMINISTRATIVO", "DISTRITO"},
+			fieldDE:        {"DISTRITO ELECTORAL"},
+			fieldJunta:     {"NUMERO DE JUNTA", "N JUNTA", "JUNTA"},
+			fieldElectores: {"TOTAL ELECTORES", "ELECTORES INSCRITOS"},
+		},
+	},
+	// Nacionales 2018, the current layout
+	"2018": {
+		Centros: map[string][]string{
+			fieldCodigo:    {"CODIGO"},
+			fieldProvincia: {"PROVINCIA"},
+			fieldCanton:    {"CANTON"},
+			fieldDE:        {"DISTRITO ELECTORAL"},
+			fieldInicial:   {"JRV INICIAL", "INICIAL"},
+			fieldFinal:     {"JRV FINAL", "FINAL"},
+			fieldTotal:     {"TOTAL JRV", "TOTAL"},
+			fieldTipo:      {"TIPO"},
+			fieldNombre:    {"NOMBRE", "NOMBRE DEL CENTRO"},
+		},
+		Juntas: map[string][]string{
+			fieldProvincia: {"PROVINCIA"},
+			fieldCanton:    {"CANTON"},
+			fieldDistrito:  {"DISTRITO"},
+			fieldDE:        {"DISTRITO ELECTORAL"},
+			fieldJunta:     {"JUNTA", "JRV"},
+			fieldElectores: {"ELECTORES", "CANTIDAD DE ELECTORES"},
+		},
+	},
+}
+
+// loadLayout returns the header texts for the election year, or for
+// every known year when year is empty.  The JSON file at path, if
+// given, adds header texts to the built-in ones.
+func loadLayout(year, path string) (*layout, error) {
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		extra := make(map[string]*layout)
+		err = json.NewDecoder(f).Decode(&extra)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		for y, l := range extra {
+			if layouts[y] == nil {
+				layouts[y] = &layout{}
+			}
+			layouts[y].merge(l)
+		}
+	}
+
+	if year != "" {
+		l, ok := layouts[year]
+		if !ok {
+			return nil, fmt.Errorf("unknown layout %q, known: %s",
+				year, strings.Join(layoutYears(), ", "))
+		}
+		return l, nil
+	}
+
+	all := &layout{}
+	for _, y := range layoutYears() {
+		all.merge(layouts[y])
+	}
+	return all, nil
+}
+
+func layoutYears() []string {
+	years := make([]string, 0, len(layouts))
+	for y := range layouts {
+		years = append(years, y)
+	}
+	sort.Strings(years)
+	return years
+}
+
+func (l *layout) merge(o *layout) {
+	add := func(dst *map[string][]string, src map[string][]string) {
+		if *dst == nil {
+			*dst = make(map[string][]string)
+		}
+		for f, aliases := range src {
+			(*dst)[f] = append((*dst)[f], aliases...)
+		}
+	}
+	add(&l.Centros, o.Centros)
+	add(&l.Juntas, o.Juntas)
+}
+
+// headerKey normalizes a header text for comparison.
+func headerKey(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, s)
+	return foldName(s)
+}
+
+// columns maps fields to column indices.
+type columns map[string]int
+
+// get returns the trimmed value of field in row, or "" if the sheet
+// has no such column.
+func (c columns) get(row []string, field string) string {
+	i, ok := c[field]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// findHeader looks for the header row among the first rows and maps
+// its columns to fields.  It returns the index of the header row, or
+// -1 when no row looks like a header.  A row naming some of the fields
+// but not all the required ones is an error.
+func findHeader(rows [][]string, aliases map[string][]string, required []string) (columns, int, error) {
+	known := make(map[string]string)
+	for f, names := range aliases {
+		for _, n := range names {
+			known[headerKey(n)] = f
+		}
+	}
+
+	var best columns
+	for i, row := range rows {
+		if i >= headerRows {
+			break
+		}
+
+		cols := make(columns)
+		for j, cell := range row {
+			f, ok := known[headerKey(cell)]
+			if !ok {
+				continue
+			}
+			if _, dup := cols[f]; !dup {
+				cols[f] = j
+			}
+		}
+
+		if len(missingFields(cols, required)) == 0 {
+			return cols, i, nil
+		}
+		if len(cols) > len(best) {
+			best = cols
+		}
+	}
+
+	// A single match is likely a title row
+	if len(best) < 2 {
+		return nil, -1, nil
+	}
+
+	return nil, -1, fmt.Errorf("missing required columns: %s",
+		strings.Join(missingFields(best, required), ", "))
+}
+
+func missingFields(cols columns, required []string) []string {
+	var missing []string
+	for _, f := range required {
+		if _, ok := cols[f]; !ok {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
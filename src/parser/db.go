@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"model"
+	"reflect"
+	"strconv"
+
+	"github.com/coopernurse/gorp"
+)
+
+func toInt(s string) int {
+	i, _ := strconv.ParseInt(s, 10, 0)
+	return int(i)
+}
+
+func toInt64(s string) (i int64) {
+	i, _ = strconv.ParseInt(s, 10, 64)
+	return
+}
+
+func getOrInsert(
+	trans *gorp.Transaction,
+	new_record interface{}) (interface{}, error) {
+
+	// Records of an election are keyed by it too
+	v := reflect.Indirect(reflect.ValueOf(new_record))
+	keys := []interface{}{v.FieldByName("Id").Int()}
+	if e := v.FieldByName("EleccionId"); e.IsValid() {
+		keys = []interface{}{e.Int(), keys[0]}
+	}
+
+	obj, err := trans.Get(new_record, keys...)
+
+	if err != nil {
+		log.Printf("W: Can't get object: %s", err)
+		return nil, err
+	}
+
+	if obj != nil {
+		return obj, nil
+	}
+
+	if err = trans.Insert(new_record); err != nil {
+		log.Printf("W: Can't insert %v: %s", new_record, err)
+		return nil, err
+	}
+
+	return new_record, nil
+}
+
+// putJunta inserts j, or updates its centro and electores if the
+// election already has it: new lists may change them.
+func putJunta(trans *gorp.Transaction, j *model.Junta) error {
+	res, err := trans.Exec(`UPDATE juntas SET centro_id = ?, electores = ?
+		WHERE eleccion_id = ? AND id = ?`, j.CentroId, j.Electores, j.EleccionId, j.Id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	return trans.Insert(j)
+}
+
+// getEleccion returns the election called nombre, adding it if it's
+// new, or the latest one if nombre is empty.  A non empty fecha is set
+// as its date.
+func getEleccion(trans *gorp.Transaction, nombre, fecha string) (*model.Eleccion, error) {
+	e, err := model.FindEleccion(trans, nombre)
+	switch {
+	case err == sql.ErrNoRows && nombre == "":
+		return nil, errors.New("the database has no elections yet, name one")
+	case err == sql.ErrNoRows:
+		e = &model.Eleccion{Nombre: nombre, Fecha: fecha}
+		log.Printf("I: New election %s", nombre)
+		return e, trans.Insert(e)
+	case err != nil:
+		return nil, err
+	}
+
+	if fecha != "" && fecha != e.Fecha {
+		e.Fecha = fecha
+		_, err = trans.Update(e)
+	}
+	return e, err
+}
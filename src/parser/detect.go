@@ -0,0 +1,257 @@
+package parser
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type inputKind int
+
+const (
+	kindUnknown  inputKind = iota
+	kindCentros            // centros de votacion XLSX, ODS or CSV
+	kindJuntas             // juntas receptoras XLSX, ODS or CSV
+	kindPadron             // PADRON_COMPLETO.txt or per provincia files
+	kindDistelec           // Distelec.txt
+)
+
+func (k inputKind) String() string {
+	switch k {
+	case kindCentros:
+		return "centros"
+	case kindJuntas:
+		return "juntas"
+	case kindPadron:
+		return "padron"
+	case kindDistelec:
+		return "distelec"
+	}
+	return "unknown"
+}
+
+// input is one of the files given to the parser, or an entry of a
+// ZIP file given to it.
+type input struct {
+	path  string
+	entry string // name inside the ZIP file at path, if any
+	kind  inputKind
+}
+
+func (in input) String() string {
+	if in.entry != "" {
+		return in.path + ":" + in.entry
+	}
+	return in.path
+}
+
+// inputs groups the inputs by kind.
+type inputs struct {
+	centros  []input
+	juntas   []input
+	padron   []input
+	distelec []input
+}
+
+func (all *inputs) add(in input) {
+	switch in.kind {
+	case kindCentros:
+		all.centros = append(all.centros, in)
+	case kindJuntas:
+		all.juntas = append(all.juntas, in)
+	case kindPadron:
+		all.padron = append(all.padron, in)
+	case kindDistelec:
+		all.distelec = append(all.distelec, in)
+	}
+}
+
+var (
+	zipMagic = []byte("PK\x03\x04")
+
+	padronLineRe   = regexp.MustCompile(`^\d{9}$`)
+	distelecLineRe = regexp.MustCompile(`^\d{6}$`)
+)
+
+// classify detects what path contains by looking at its content, not
+// its name.  ZIP files other than spreadsheets are searched for
+// padron and Distelec entries, text files other than those are
+// expected to be CSV spreadsheets, told apart by the header texts of
+// headers.
+func classify(path string, headers *layout) ([]input, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(zipMagic))
+	n, _ := io.ReadFull(f, magic)
+
+	if !bytes.Equal(magic[:n], zipMagic) {
+		if _, err := f.Seek(0, 0); err != nil {
+			return nil, err
+		}
+		kind, err := sniffText(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		if kind == kindUnknown {
+			kind, err = classifySheets(csvSource(path), headers)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return []input{{path: path, kind: kind}}, nil
+	}
+
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	var found []input
+	for _, e := range z.File {
+		if e.Name == "[Content_Types].xml" || e.Name == "mimetype" {
+			src, err := openRows(path)
+			if err != nil {
+				break
+			}
+			kind, err := classifySheets(src, headers)
+			if err != nil {
+				return nil, err
+			}
+			return []input{{path: path, kind: kind}}, nil
+		}
+	}
+
+	for _, e := range z.File {
+		if e.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := e.Open()
+		if err != nil {
+			return nil, err
+		}
+		kind, err := sniffText(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s:%s: %s", path, e.Name, err)
+		}
+		if kind != kindUnknown {
+			found = append(found, input{path: path, entry: e.Name, kind: kind})
+		}
+	}
+
+	return found, nil
+}
+
+// sniffText looks at the first line of a text file to tell padron
+// lines (cedula,codelec,sexo,vencimiento,junta,nombre,apellido,apellido)
+// from Distelec lines (codelec,provincia,canton,distrito).
+func sniffText(r io.Reader) (inputKind, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := strings.TrimSpace(strings.TrimPrefix(s.Text(), "\ufeff"))
+		if l == "" {
+			continue
+		}
+		f := strings.Split(l, ",")
+		switch {
+		case len(f) == 8 && padronLineRe.MatchString(strings.TrimSpace(f[0])):
+			return kindPadron, nil
+		case len(f) == 4 && distelecLineRe.MatchString(strings.TrimSpace(f[0])):
+			return kindDistelec, nil
+		}
+		return kindUnknown, nil
+	}
+	return kindUnknown, s.Err()
+}
+
+// classifySheets tells the centros list, with junta ranges per centro,
+// from the juntas list, with the number of electores per junta: the
+// first sheet with a header row naming the required fields of either
+// one, in any of the layouts of headers.
+func classifySheets(src rowSource, headers *layout) (inputKind, error) {
+	sheets, err := src.Sheets()
+	if err != nil {
+		return kindUnknown, err
+	}
+
+	for _, sheet := range sheets {
+		// A header with some fields missing is reported when the
+		// list is read
+		if _, i, _ := findHeader(sheet.rows, headers.Juntas, juntasRequired); i >= 0 {
+			return kindJuntas, nil
+		}
+		if _, i, _ := findHeader(sheet.rows, headers.Centros, centrosRequired); i >= 0 {
+			return kindCentros, nil
+		}
+	}
+
+	return kindUnknown, nil
+}
+
+// open returns the content of in.
+func (in input) open() (io.ReadCloser, error) {
+	if in.entry == "" {
+		return os.Open(in.path)
+	}
+
+	z, err := zip.OpenReader(in.path)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range z.File {
+		if e.Name == in.entry {
+			rc, err := e.Open()
+			if err != nil {
+				z.Close()
+				return nil, err
+			}
+			return zipEntry{rc, z}, nil
+		}
+	}
+	z.Close()
+	return nil, fmt.Errorf("%s: no such entry", in)
+}
+
+// size returns the size of in once uncompressed, zero if unknown.
+func (in input) size() int64 {
+	if in.entry == "" {
+		fi, err := os.Stat(in.path)
+		if err != nil {
+			return 0
+		}
+		return fi.Size()
+	}
+
+	z, err := zip.OpenReader(in.path)
+	if err != nil {
+		return 0
+	}
+	defer z.Close()
+	for _, e := range z.File {
+		if e.Name == in.entry {
+			return int64(e.UncompressedSize64)
+		}
+	}
+	return 0
+}
+
+// zipEntry closes the ZIP file along with the entry.
+type zipEntry struct {
+	io.ReadCloser
+	z *zip.ReadCloser
+}
+
+func (e zipEntry) Close() error {
+	e.ReadCloser.Close()
+	return e.z.Close()
+}
@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Distelec is the canonical geography published by the TSE in
+// Distelec.txt, one distrito per line:
+//
+//	codele,provincia,canton,distrito
+//
+// where codele is PCCDDD: provincia, canton and distrito codes.
+type Distelec struct {
+	Provincias map[string]string
+	Cantones   map[string]string
+	Distritos  map[string]string
+}
+
+func NewDistelec() *Distelec {
+	return &Distelec{
+		Provincias: make(map[string]string),
+		Cantones:   make(map[string]string),
+		Distritos:  make(map[string]string),
+	}
+}
+
+// Read adds the distritos listed in r, a Distelec.txt, and returns a
+// diagnostic for each line that can't be used.
+func (d *Distelec) Read(name string, r io.Reader) ([]diagnostic, error) {
+	var problems []diagnostic
+
+	br, enc, err := openText(r, textEncoding)
+	if err != nil {
+		return nil, err
+	}
+	diags.encoding(name, enc)
+	dec := encodings[enc].NewDecoder()
+
+	s := bufio.NewScanner(br)
+	for n := 1; s.Scan(); n++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+
+		line, err := decodeString(dec, enc, s.Text())
+		if err != nil {
+			problems = append(problems, diagnostic{
+				Severity: sevError,
+				Rule:     ruleEncoding,
+				File:     name,
+				Row:      n,
+				Value:    s.Text(),
+				Message:  err.Error(),
+			})
+			continue
+		}
+
+		bad := func(rule, column, value, msg string) {
+			problems = append(problems, diagnostic{
+				Severity: sevError,
+				Rule:     rule,
+				File:     name,
+				Row:      n,
+				Column:   column,
+				Value:    value,
+				Message:  msg,
+			})
+		}
+
+		f := strings.Split(line, ",")
+		if len(f) != 4 {
+			bad(ruleFieldCount, "", line, fmt.Sprintf("expected 4 fields, got %d", len(f)))
+			continue
+		}
+		for i := range f {
+			f[i] = strings.TrimSpace(f[i])
+		}
+
+		id := f[0]
+		if !distelecLineRe.MatchString(id) {
+			bad(ruleInvalidId, "codele", id, "invalid codele")
+			continue
+		}
+
+		set := func(what string, m map[string]string, id, nombre string) {
+			if cur, ok := m[id]; ok && cur != nombre {
+				bad(ruleConflictingName, what, nombre,
+					fmt.Sprintf("%s %s is already %q", what, id, cur))
+				return
+			}
+			m[id] = nombre
+		}
+		set("provincia", d.Provincias, id[0:1], f[1])
+		set("canton", d.Cantones, id[0:3], f[2])
+		set("distrito", d.Distritos, id[0:6], f[3])
+	}
+
+	return problems, s.Err()
+}
+
+// sameName compares geographic names ignoring case, accents and
+// spacing, which vary between TSE publications.
+func sameName(a, b string) bool {
+	return foldName(a) == foldName(b)
+}
+
+var accents = strings.NewReplacer(
+	"Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ü", "U",
+)
+
+func foldName(s string) string {
+	return accents.Replace(strings.Join(strings.Fields(strings.ToUpper(s)), " "))
+}
+
+// CrossCheck compares the geography reconstructed from the
+// spreadsheets in p with d and describes every disagreement: codes
+// only present in one of them and codes with different names.
+func (d *Distelec) CrossCheck(p *Padron) []diagnostic {
+	var problems []diagnostic
+
+	mismatch := func(what, value, format string, args ...interface{}) {
+		problems = append(problems, diagnostic{
+			Severity: sevWarning,
+			Rule:     ruleDistelec,
+			Column:   what,
+			Value:    value,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	compare := func(what string, canonical, derived map[string]string) {
+		for _, id := range sortedKeys(derived) {
+			name, ok := canonical[id]
+			switch {
+			case !ok:
+				mismatch(what, derived[id], "%s %s is not in Distelec", what, id)
+			case !sameName(name, derived[id]):
+				mismatch(what, derived[id], "%s %s is %q in Distelec, the spreadsheets say",
+					what, id, name)
+			}
+		}
+		for _, id := range sortedKeys(canonical) {
+			if _, ok := derived[id]; !ok {
+				mismatch(what, canonical[id], "%s %s is not in the spreadsheets", what, id)
+			}
+		}
+	}
+
+	// Without the juntas list there is nothing to compare with
+	if len(p.DEs) == 0 {
+		return nil
+	}
+
+	compare("provincia", d.Provincias, p.Provincias)
+	compare("canton", d.Cantones, p.Cantones)
+	compare("distrito", d.Distritos, p.Distritos)
+
+	for _, id := range sortedKeys(p.DEs) {
+		if _, ok := d.Distritos[id[0:6]]; !ok {
+			mismatch("distrito electoral", p.DEs[id], "distrito electoral %s belongs to unknown distrito %s",
+				id, id[0:6])
+		}
+	}
+
+	return problems
+}
+
+// Apply makes d the source of the geography in p.  Codes only found
+// in the spreadsheets are kept, the distritos electorales refer to
+// them.
+func (d *Distelec) Apply(p *Padron) {
+	for id, nombre := range d.Provincias {
+		p.Provincias[id] = nombre
+	}
+	for id, nombre := range d.Cantones {
+		p.Cantones[id] = nombre
+	}
+	for id, nombre := range d.Distritos {
+		p.Distritos[id] = nombre
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/transform"
+)
+
+// The TSE publishes the padron and Distelec.txt in ISO-8859-15, but
+// copies that went through other hands may be UTF-8 or Windows-1252.
+var encodings = map[string]encoding.Encoding{
+	"iso-8859-15":  charmap.ISO8859_15,
+	"windows-1252": charmap.Windows1252,
+	"utf-8":        encoding.Nop,
+}
+
+// textEncoding is the encoding of the padron and Distelec.txt, "auto"
+// to detect it.  Set from the command line.
+var textEncoding = "auto"
+
+// Bytes looked at to detect the encoding
+const sniffSize = 1 << 20
+
+var errUTF16 = errors.New("UTF-16 is not supported")
+
+// sniffEncoding guesses the encoding of sample, returning its name and
+// the length of its byte order mark:
+//
+//   - a byte order mark tells,
+//   - valid UTF-8 with non-ASCII characters is UTF-8,
+//   - bytes 0x80 to 0x9F are control characters in ISO-8859 but
+//     letters and punctuation in Windows-1252,
+//   - anything else is fallback.
+func sniffEncoding(sample []byte, fallback string) (string, int, error) {
+	switch {
+	case bytes.HasPrefix(sample, []byte("\xef\xbb\xbf")):
+		return "utf-8", 3, nil
+	case bytes.HasPrefix(sample, []byte("\xff\xfe")),
+		bytes.HasPrefix(sample, []byte("\xfe\xff")):
+		return "", 0, errUTF16
+	}
+
+	ascii := true
+	c1 := 0
+	for _, b := range sample {
+		if b >= 0x80 {
+			ascii = false
+		}
+		if b >= 0x80 && b <= 0x9f {
+			c1++
+		}
+	}
+
+	switch {
+	case ascii:
+		return fallback, 0, nil
+	case utf8.Valid(trimPartialRune(sample)):
+		return "utf-8", 0, nil
+	case c1 > 0:
+		return "windows-1252", 0, nil
+	}
+	return fallback, 0, nil
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence at the end of b,
+// where the sample may have cut it.
+func trimPartialRune(b []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// openText returns a reader positioned after the byte order mark of r,
+// if any, and the name of the encoding of r: enc, or detected if it is
+// "auto".
+func openText(r io.Reader, enc string) (*bufio.Reader, string, error) {
+	br := bufio.NewReaderSize(r, sniffSize)
+	sample, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+
+	name, bom, err := sniffEncoding(sample, "iso-8859-15")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := br.Discard(bom); err != nil {
+		return nil, "", err
+	}
+
+	if enc != "auto" {
+		if _, ok := encodings[enc]; !ok {
+			return nil, "", fmt.Errorf("unknown encoding %q", enc)
+		}
+		name = enc
+	}
+
+	return br, name, nil
+}
+
+// OpenText returns r decoded to UTF-8 from enc, or from the encoding
+// detected as for the import if it is "auto", and the name of the
+// encoding.  Invalid UTF-8 in a UTF-8 file is left for the caller to
+// reject.
+func OpenText(r io.Reader, enc string) (io.Reader, string, error) {
+	br, name, err := openText(r, enc)
+	if err != nil {
+		return nil, "", err
+	}
+	if name == "utf-8" {
+		return br, name, nil
+	}
+	return transform.NewReader(br, encodings[name].NewDecoder()), name, nil
+}
+
+// decodeString decodes s, failing on invalid UTF-8 when that is the
+// encoding.
+func decodeString(dec transform.Transformer, name, s string) (string, error) {
+	if name == "utf-8" {
+		if !utf8.ValidString(s) {
+			return "", errors.New("invalid UTF-8")
+		}
+		return s, nil
+	}
+	s, _, err := transform.String(dec, s)
+	return s, err
+}
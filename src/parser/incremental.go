@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"model"
+	"strings"
+
+	"github.com/coopernurse/gorp"
+)
+
+// In incremental mode the padron is loaded into staging tables and
+// only the differences with personas and padron are applied, each one
+// recorded in cambios.  The staging tables are in the database being
+// built, so that an interrupted import can be resumed, and dropped once
+// the changes are applied.
+
+var stagingLoad = loadTarget{"nuevas_personas", "nuevo_padron", []index{
+	{"idx_nuevo_padron_persona_id",
+		"CREATE UNIQUE INDEX idx_nuevo_padron_persona_id ON nuevo_padron(persona_id)"},
+}, ""}
+
+// createStaging creates the tables of stagingLoad.
+func createStaging(trans *gorp.Transaction) error {
+	_, err := trans.Exec(`
+		DROP TABLE IF EXISTS nuevas_personas;
+		DROP TABLE IF EXISTS nuevo_padron;
+		CREATE TABLE nuevas_personas (
+			id INTEGER PRIMARY KEY,
+			cedula TEXT NOT NULL,
+			expiracion INTEGER NOT NULL,
+			nombre TEXT NOT NULL,
+			apellido_1 TEXT NOT NULL,
+			apellido_2 TEXT NOT NULL,
+			genero INTEGER NOT NULL
+		);
+		CREATE TABLE nuevo_padron (
+			eleccion_id INTEGER NOT NULL,
+			persona_id INTEGER NOT NULL,
+			junta_id INTEGER NOT NULL
+		)`)
+	return err
+}
+
+// Kinds of changes
+var cambioTipos = []string{
+	model.CambioAlta,
+	model.CambioBaja,
+	model.CambioTraslado,
+	model.CambioCorreccion,
+}
+
+// Each query selects the changes of a kind in the padron of an
+// election, the only parameter, as (persona_id, junta_anterior,
+// junta_nueva)
+var cambioQueries = map[string]string{
+	model.CambioAlta: `SELECT np.persona_id, NULL, np.junta_id
+		FROM nuevo_padron np
+		LEFT JOIN padron pa
+			ON pa.eleccion_id = np.eleccion_id AND pa.persona_id = np.persona_id
+		WHERE np.eleccion_id = ?1 AND pa.persona_id IS NULL`,
+	model.CambioBaja: `SELECT pa.persona_id, pa.junta_id, NULL
+		FROM padron pa
+		LEFT JOIN nuevo_padron np ON np.persona_id = pa.persona_id
+		WHERE pa.eleccion_id = ?1 AND np.persona_id IS NULL`,
+	model.CambioTraslado: `SELECT pa.persona_id, pa.junta_id, np.junta_id
+		FROM padron pa
+		JOIN nuevo_padron np ON np.persona_id = pa.persona_id
+		WHERE pa.eleccion_id = ?1 AND np.junta_id != pa.junta_id`,
+	model.CambioCorreccion: `SELECT p.id, pa.junta_id, pa.junta_id
+		FROM padron pa
+		JOIN personas p ON p.id = pa.persona_id
+		JOIN nuevas_personas n ON n.id = p.id
+		WHERE pa.eleccion_id = ?1 AND (n.nombre != p.nombre
+			OR n.apellido_1 != p.apellido_1
+			OR n.apellido_2 != p.apellido_2
+			OR n.expiracion != p.expiracion
+			OR n.genero != p.genero)`,
+}
+
+var personasList = strings.Join(personasCols, ", ")
+
+// Statements applying the changes of a kind recorded for an import,
+// parameters ?1 the election, ?2 the import and ?3 the kind.  Personas
+// are shared by the elections, an alta updates one another election
+// already has.
+const cambioIds = `SELECT persona_id FROM cambios WHERE importacion_id = ?2 AND tipo = ?3`
+
+var cambioApply = map[string][]string{
+	model.CambioAlta: {
+		`INSERT OR REPLACE INTO personas (` + personasList + `)
+			SELECT ` + personasList + ` FROM nuevas_personas WHERE id IN (` + cambioIds + `)`,
+		`INSERT INTO padron (eleccion_id, persona_id, junta_id)
+			SELECT ?1, persona_id, junta_id FROM nuevo_padron
+			WHERE persona_id IN (` + cambioIds + `)`,
+	},
+	model.CambioBaja: {
+		`DELETE FROM padron WHERE eleccion_id = ?1 AND persona_id IN (` + cambioIds + `)`,
+		`DELETE FROM personas WHERE id IN (` + cambioIds + `)
+			AND id NOT IN (SELECT persona_id FROM padron)`,
+	},
+	model.CambioTraslado: {
+		`UPDATE padron SET junta_id =
+			(SELECT junta_id FROM nuevo_padron WHERE persona_id = padron.persona_id)
+		WHERE eleccion_id = ?1 AND persona_id IN (` + cambioIds + `)`,
+	},
+	model.CambioCorreccion: {
+		`UPDATE personas SET
+			nombre = (SELECT nombre FROM nuevas_personas n WHERE n.id = personas.id),
+			apellido_1 = (SELECT apellido_1 FROM nuevas_personas n WHERE n.id = personas.id),
+			apellido_2 = (SELECT apellido_2 FROM nuevas_personas n WHERE n.id = personas.id),
+			expiracion = (SELECT expiracion FROM nuevas_personas n WHERE n.id = personas.id),
+			genero = (SELECT genero FROM nuevas_personas n WHERE n.id = personas.id)
+		WHERE id IN (` + cambioIds + `)`,
+	},
+}
+
+// applyChanges records in cambios the differences between the staged
+// padron and the current one of the election of the import imp, under
+// imp, applies them and drops the staging tables.
+func applyChanges(trans *gorp.Transaction, imp *model.Importacion) error {
+	counts := make(map[string]int64)
+
+	for _, tipo := range cambioTipos {
+		res, err := trans.Exec(`INSERT INTO cambios
+			(importacion_id, persona_id, junta_anterior, junta_nueva, tipo)
+			SELECT ?2, c.*, ?3 FROM (`+cambioQueries[tipo]+`) AS c`,
+			imp.EleccionId, imp.Id, tipo)
+		if err != nil {
+			return fmt.Errorf("%s: %s", tipo, err)
+		}
+		counts[tipo], _ = res.RowsAffected()
+	}
+
+	// Every change is selected from the current tables, so they are
+	// only modified once all are recorded
+	for _, tipo := range cambioTipos {
+		if counts[tipo] == 0 {
+			continue
+		}
+		for _, stmt := range cambioApply[tipo] {
+			if _, err := trans.Exec(stmt, imp.EleccionId, imp.Id, tipo); err != nil {
+				return fmt.Errorf("%s: %s", tipo, err)
+			}
+		}
+	}
+
+	log.Printf("I: %d altas, %d bajas, %d traslados, %d correcciones",
+		counts[model.CambioAlta], counts[model.CambioBaja],
+		counts[model.CambioTraslado], counts[model.CambioCorreccion])
+
+	_, err := trans.Exec(`
+		DROP TABLE nuevas_personas;
+		DROP TABLE nuevo_padron`)
+	return err
+}
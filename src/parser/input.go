@@ -0,0 +1,351 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+)
+
+type Padron struct {
+	Provincias map[string]string
+	Cantones   map[string]string
+	Distritos  map[string]string
+	DEs        map[string]string
+	Centros    map[int]Centro // by row, see centroId for the id
+	Juntas     map[int]Junta
+
+	// JuntaCentro maps each junta to the row of its centro in
+	// Centros, see Validate
+	JuntaCentro map[int]int
+
+	// layout names the columns of the spreadsheets
+	layout *layout
+}
+
+type Centro struct {
+	Id           int
+	JuntaStartId int
+	JuntaEndId   int
+	Tipo         string
+	Nombre       string
+	DE           string
+
+	origin origin
+}
+
+type Junta struct {
+	Id        int
+	Electores int
+	DEId      string
+
+	origin origin
+}
+
+type Row xlsx.Row
+
+func (r *Row) String() string {
+	s := make([]string, 0, len(r.Cells))
+	for _, c := range r.Cells {
+		s = append(s, c.Value)
+	}
+	return strings.Join(s, ",")
+}
+
+func NewPadron(l *layout) *Padron {
+	return &Padron{
+		layout:     l,
+		Provincias: make(map[string]string),
+		Cantones:   make(map[string]string),
+		Distritos:  make(map[string]string),
+		DEs:        make(map[string]string),
+		Centros:    make(map[int]Centro),
+		Juntas:     make(map[int]Junta),
+	}
+}
+
+// headerSheets calls f with the rows following the header of every
+// sheet in the spreadsheet fn, and the columns found in the header.
+// Sheets without a header are skipped, but missing required columns
+// are fatal: the TSE changed the layout.
+func headerSheets(fn string, aliases map[string][]string, required []string,
+	f func(sheet string, first int, rows [][]string, cols columns)) {
+
+	src, err := openRows(fn)
+	if err != nil {
+		log.Fatalf(`E: Can't open %s: %s. Abort.`, fn, err)
+	}
+	sheets, err := src.Sheets()
+	if err != nil {
+		log.Fatalf(`E: Can't read %s: %s. Abort.`, fn, err)
+	}
+
+	found := false
+	for _, sheet := range sheets {
+		rows := sheet.rows
+		cols, at, err := findHeader(rows, aliases, required)
+		if err != nil {
+			diags.fatal(diagnostic{
+				Severity: sevError,
+				Rule:     ruleMissingColumn,
+				File:     fn,
+				Sheet:    sheet.name,
+				Message:  err.Error(),
+			})
+		}
+		if at < 0 {
+			log.Printf("W: %s: sheet %q has no header row, skipped", fn, sheet.name)
+			continue
+		}
+		found = true
+		f(sheet.name, at+1, rows[at+1:], cols)
+	}
+
+	if !found {
+		diags.fatal(diagnostic{
+			Severity: sevError,
+			Rule:     ruleMissingColumn,
+			File:     fn,
+			Message:  "no header row found, expected columns: " + strings.Join(required, ", "),
+		})
+	}
+}
+
+func (p *Padron) ProcessCentros(fn string) {
+	id := len(p.Centros) + 1
+
+	headerSheets(fn, p.layout.Centros, centrosRequired, func(sheet string, first int, rows [][]string, cols columns) {
+		for i, r := range rows {
+			at := origin{fn, sheet, first + i + 1}
+			bad := func(column, rule, value, msg string) {
+				diags.add(at.diag(sevError, rule, column, value, msg))
+			}
+
+			inicial := cols.get(r, fieldInicial)
+			if inicial == "" {
+				continue
+			}
+
+			j0, err := strconv.Atoi(inicial)
+			if err != nil {
+				bad(fieldInicial, ruleNotANumber, inicial, "JRV inicial is not a number")
+				continue
+			}
+
+			final := cols.get(r, fieldFinal)
+			j1, err := strconv.Atoi(final)
+			if err != nil {
+				bad(fieldFinal, ruleNotANumber, final, "JRV final is not a number")
+				continue
+			}
+
+			// The total is redundant, but when present it tells
+			// whether the range was read correctly
+			if total := cols.get(r, fieldTotal); total != "" {
+				t, err := strconv.Atoi(total)
+				if err != nil {
+					bad(fieldTotal, ruleNotANumber, total, "JRV total is not a number")
+					continue
+				}
+				if t != j1-j0+1 {
+					bad(fieldTotal, ruleRangeTotal, total,
+						fmt.Sprintf("juntas %d to %d are %d, not %d", j0, j1, j1-j0+1, t))
+					continue
+				}
+			}
+
+			de := cols.get(r, fieldDE)
+
+			if codigo := cols.get(r, fieldCodigo); codigo != "" && len(codigo) != 6 {
+				bad(fieldCodigo, ruleCodigoLength, codigo, "codigo is not 6 digits long")
+				continue
+			}
+
+			tipo := cols.get(r, fieldTipo)
+			nombre := strings.TrimSpace(strings.TrimPrefix(cols.get(r, fieldNombre), tipo))
+
+			p.Centros[id] = Centro{
+				Id:           id,
+				JuntaStartId: j0,
+				JuntaEndId:   j1,
+				Tipo:         tipo,
+				Nombre:       nombre,
+				DE:           de,
+				origin:       at,
+			}
+			id++
+		}
+	})
+}
+
+func (p *Padron) ProcessJuntas(fn string) {
+	split := func(s string) (id, name string, err error) {
+		f := strings.SplitN(strings.TrimSpace(s), " ", 2)
+		if len(f) != 2 {
+			err = errors.New("Unexpected format")
+			return "", "", err
+		}
+		return f[0], f[1], nil
+	}
+
+	headerSheets(fn, p.layout.Juntas, juntasRequired, func(sheet string, first int, rows [][]string, cols columns) {
+		for i, r := range rows {
+			at := origin{fn, sheet, first + i + 1}
+			bad := func(column, rule, value, msg string) {
+				diags.add(at.diag(sevError, rule, column, value, msg))
+			}
+
+			// Rows without a junta number are titles or
+			// subtotals
+			id, err := strconv.Atoi(cols.get(r, fieldJunta))
+			if err != nil {
+				continue
+			}
+
+			// Provincia
+			tmp, provincia, err := split(cols.get(r, fieldProvincia))
+			if err != nil {
+				bad(fieldProvincia, ruleBadFormat, cols.get(r, fieldProvincia), "expected code and name")
+				continue
+			}
+			provincia_id := tmp
+
+			// Canton
+			tmp, canton, err := split(cols.get(r, fieldCanton))
+			if err != nil {
+				bad(fieldCanton, ruleBadFormat, cols.get(r, fieldCanton), "expected code and name")
+				continue
+			}
+			canton_id := provincia_id + tmp
+
+			// Distrito
+			tmp, distrito, err := split(cols.get(r, fieldDistrito))
+			if err != nil {
+				bad(fieldDistrito, ruleBadFormat, cols.get(r, fieldDistrito), "expected code and name")
+				continue
+			}
+			distrito_id := canton_id + tmp
+
+			// Distrito electoral
+			tmp, de, err := split(cols.get(r, fieldDE))
+			if err != nil {
+				bad(fieldDE, ruleBadFormat, cols.get(r, fieldDE), "expected code and name")
+				continue
+			}
+			de_id := tmp
+
+			// This checks that the ID format is correct:
+			// PCCDDDNNN
+			if len(de_id) < 6 || de_id[0:6] != distrito_id {
+				bad(fieldDE, ruleDEDistrito, cols.get(r, fieldDE),
+					"distrito electoral is not in distrito "+distrito_id)
+				continue
+			}
+
+			// Electores
+			electores := cols.get(r, fieldElectores)
+			t, err := strconv.Atoi(electores)
+			if err != nil {
+				bad(fieldElectores, ruleNotANumber, electores, "electores is not a number")
+				continue
+			}
+
+			if prev, ok := p.Juntas[id]; ok {
+				bad(fieldJunta, ruleDuplicateJunta, strconv.Itoa(id),
+					fmt.Sprintf("junta already listed in %s row %d", prev.origin.file, prev.origin.row))
+				continue
+			}
+
+			// Every row repeats the geography, it must agree
+			// with the previous ones.  The first name wins.
+			set := func(column string, m map[string]string, id, name string) {
+				if cur, ok := m[id]; ok {
+					if cur != name {
+						diags.add(at.diag(sevWarning, ruleConflictingName, column, name,
+							fmt.Sprintf("%s %s is already %q", column, id, cur)))
+					}
+					return
+				}
+				m[id] = name
+			}
+
+			set(fieldProvincia, p.Provincias, provincia_id, provincia)
+			set(fieldCanton, p.Cantones, canton_id, canton)
+			set(fieldDistrito, p.Distritos, distrito_id, distrito)
+			set(fieldDE, p.DEs, de_id, de)
+			p.Juntas[id] = Junta{
+				Id:        id,
+				Electores: t,
+				DEId:      de_id,
+				origin:    at,
+			}
+		}
+	})
+}
+
+func processInput(all *inputs, l *layout) *Padron {
+	padron := NewPadron(l)
+
+	for _, in := range all.centros {
+		padron.ProcessCentros(in.path)
+	}
+	for _, in := range all.juntas {
+		padron.ProcessJuntas(in.path)
+	}
+
+	// Distelec.txt is the authoritative source for provincias,
+	// cantones and distritos, but the spreadsheets are checked
+	// against it
+	if len(all.distelec) > 0 {
+		d := NewDistelec()
+		for _, in := range all.distelec {
+			r, err := in.open()
+			if err != nil {
+				log.Fatal(err)
+			}
+			problems, err := d.Read(in.String(), r)
+			r.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, p := range problems {
+				diags.add(p)
+			}
+		}
+
+		for _, p := range d.CrossCheck(padron) {
+			diags.add(p)
+		}
+
+		d.Apply(padron)
+	}
+
+	// Up to this point we have processed all the information we
+	// have, now it's time to reconstruct data.
+
+	// We tables with:
+	//
+	// provincia_id, name
+	// canton_id, name
+	// distrito_id, name
+	// de_id, name
+	//
+	// Centros point to a set of juntas
+	// Juntas point to DEs
+
+	// This is how you obtain all the data starting from DE id
+	//
+	// provincia := padron.Provincias[deid[0:1]]
+	// canton := padron.Cantones[deid[0:3]]
+	// distrito := padron.Distritos[deid[0:6]]
+	// de := padron.DEs[deid[0:9]]
+
+	if len(padron.Centros) > 0 && len(padron.Juntas) > 0 {
+		padron.Validate()
+	}
+
+	return padron
+}
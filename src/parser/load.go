@@ -0,0 +1,520 @@
+package parser
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"model"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coopernurse/gorp"
+)
+
+const (
+	// Lines handed to each decoding worker at a time
+	chunkSize = 4096
+
+	// Rows per INSERT statement.  SQLite allows 999 parameters per
+	// statement and personas has 7 columns.
+	batchSize = 128
+
+	progressInterval = 5 * time.Second
+)
+
+type index struct{ name, def string }
+
+// Indexes dropped during the load and created afterwards, it's much
+// faster to build them once than to update them on every insert.
+var deferredIndexes = []index{
+	{"idx_personas_cedula", "CREATE UNIQUE INDEX idx_personas_cedula ON personas(cedula)"},
+	{"idx_padron_persona_id",
+		"CREATE UNIQUE INDEX idx_padron_persona_id ON padron(eleccion_id, persona_id)"},
+	{"idx_padron_junta_id", "CREATE INDEX idx_padron_junta_id ON padron(eleccion_id, junta_id)"},
+	{"idx_padron_persona", "CREATE INDEX idx_padron_persona ON padron(persona_id)"},
+}
+
+// loadTarget names the tables a padron is loaded into and their
+// deferred indexes.  If merge is set, finish merges the personas
+// loaded into it, updating those it already has.
+type loadTarget struct {
+	personas string
+	padron   string
+	indexes  []index
+	merge    string
+}
+
+// Personas are shared by the elections, those in a new padron replace
+// the ones another election loaded, so they are loaded apart first.
+var fullLoad = loadTarget{"personas_carga", "padron", deferredIndexes, "personas"}
+
+// createCarga creates the table of the personas of fullLoad.
+func createCarga(trans *gorp.Transaction) error {
+	_, err := trans.Exec(`
+		DROP TABLE IF EXISTS personas_carga;
+		CREATE TABLE personas_carga (
+			id INTEGER PRIMARY KEY,
+			cedula TEXT NOT NULL,
+			expiracion INTEGER NOT NULL,
+			nombre TEXT NOT NULL,
+			apellido_1 TEXT NOT NULL,
+			apellido_2 TEXT NOT NULL,
+			genero INTEGER NOT NULL
+		)`)
+	return err
+}
+
+// record is a decoded line of the padron file.
+type record struct {
+	persona model.Persona
+	junta   int64
+}
+
+// lineError describes why a line of the padron can't be used.
+type lineError struct {
+	rule   string
+	column string
+	msg    string
+}
+
+func (e lineError) Error() string {
+	return e.msg
+}
+
+// decodeLine parses a line of the padron:
+//
+//	0: id
+//	1: distrito electoral
+//	2: sexo
+//	3: vencimiento
+//	4: junta
+//	5: nombre
+//	6: apellido 1
+//	7: apellido 2
+func decodeLine(l string) (record, error) {
+	fields := strings.Split(l, ",")
+	if len(fields) < 8 {
+		return record{}, lineError{ruleFieldCount, "",
+			fmt.Sprintf("expected 8 fields, got %d", len(fields))}
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	r := record{
+		persona: model.Persona{
+			Id:         toInt64(fields[0]),
+			Cedula:     fields[0],
+			Expiracion: toInt64(fields[3]),
+			Nombre:     fields[5],
+			Apellido1:  fields[6],
+			Apellido2:  fields[7],
+			Genero:     toInt(fields[2]),
+		},
+		junta: toInt64(fields[4]),
+	}
+
+	if r.persona.Id == 0 {
+		return record{}, lineError{ruleInvalidId, "cedula", "invalid cedula"}
+	}
+	if r.junta == 0 {
+		return record{}, lineError{ruleInvalidId, "junta", "invalid junta"}
+	}
+
+	return r, nil
+}
+
+// checkpointRows is the number of padron rows loaded between commits,
+// an interrupted import resumes from the last one.
+var checkpointRows = 250000
+
+// afterCheckpoint, if set, is called after each checkpoint is
+// committed, by the tests to interrupt an import.
+var afterCheckpoint func()
+
+// loader bulk loads padron records inside a transaction, committing it
+// and starting a new one at each checkpoint.
+type loader struct {
+	dbmap    *gorp.DbMap
+	trans    *gorp.Transaction
+	target   loadTarget
+	eleccion int64
+
+	personas *sql.Stmt // batchSize rows
+	padron   *sql.Stmt // batchSize rows
+	pending  []record
+
+	rows    int
+	skipped int
+	start   time.Time
+}
+
+func insertStmt(table string, cols []string, rows int) string {
+	row := "(?" + strings.Repeat(",?", len(cols)-1) + ")"
+	return fmt.Sprintf("INSERT OR IGNORE INTO %s (%s) VALUES %s",
+		table, strings.Join(cols, ","),
+		row+strings.Repeat(","+row, rows-1))
+}
+
+var (
+	personasCols = []string{"id", "cedula", "expiracion", "nombre",
+		"apellido_1", "apellido_2", "genero"}
+	padronCols = []string{"eleccion_id", "persona_id", "junta_id"}
+)
+
+// newLoader prepares trans, of dbmap, for a bulk load of the padron of
+// the election eleccion into the tables of target, dropping the
+// deferred indexes.  finish must be called to recreate them.
+func newLoader(dbmap *gorp.DbMap, trans *gorp.Transaction, target loadTarget,
+	eleccion int64) (*loader, error) {
+
+	for _, idx := range target.indexes {
+		if _, err := trans.Exec("DROP INDEX IF EXISTS " + idx.name); err != nil {
+			return nil, err
+		}
+	}
+
+	l := &loader{
+		dbmap:    dbmap,
+		trans:    trans,
+		target:   target,
+		eleccion: eleccion,
+		pending:  make([]record, 0, batchSize),
+		start:    time.Now(),
+	}
+	if err := l.prepare(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// prepare prepares the batchSize rows inserts in l.trans.
+func (l *loader) prepare() error {
+	var err error
+	l.personas, err = l.trans.Prepare(insertStmt(l.target.personas, personasCols, batchSize))
+	if err != nil {
+		return err
+	}
+
+	l.padron, err = l.trans.Prepare(insertStmt(l.target.padron, padronCols, batchSize))
+	if err != nil {
+		l.personas.Close()
+		return err
+	}
+	return nil
+}
+
+// checkpoint records in p that lines lines of its file are loaded, and
+// that it's done if so, and commits.
+func (l *loader) checkpoint(p *model.Progreso, lines int64, rows int, done bool) error {
+	if err := l.flush(); err != nil {
+		return err
+	}
+
+	p.Lineas, p.Filas, p.Terminado = lines, int64(rows), done
+	p.Fecha = time.Now().Unix()
+	if _, err := l.trans.Update(p); err != nil {
+		return err
+	}
+
+	l.personas.Close()
+	l.padron.Close()
+	if err := l.trans.Commit(); err != nil {
+		return err
+	}
+
+	if afterCheckpoint != nil {
+		afterCheckpoint()
+	}
+
+	trans, err := l.dbmap.Begin()
+	if err != nil {
+		return err
+	}
+	l.trans = trans
+	return l.prepare()
+}
+
+// load decodes the padron in r, of size bytes, spreading the work
+// across all CPUs, and inserts the records, committing a checkpoint in
+// p every checkpointRows rows and at the end.  The lines p says are
+// already loaded are only decoded, for the report.
+func (l *loader) load(name string, r io.Reader, size int64, p *model.Progreso) error {
+	in := &countingReader{r: r}
+	bar := newProgress(name, size, in)
+	defer bar.done()
+
+	r, enc, err := openText(in, textEncoding)
+	if err != nil {
+		return err
+	}
+	log.Printf("I: %s: %s", name, enc)
+	diags.encoding(name, enc)
+
+	type chunk struct {
+		seq   int
+		lines []string
+		recs  []record
+	}
+
+	workers := runtime.NumCPU()
+	chunks := make(chan chunk, workers)
+	decoded := make(chan chunk, workers)
+
+	var readErr error
+	go func() {
+		defer close(chunks)
+		s := bufio.NewScanner(r)
+		c := chunk{lines: make([]string, 0, chunkSize)}
+		for s.Scan() {
+			c.lines = append(c.lines, s.Text())
+			if len(c.lines) == chunkSize {
+				chunks <- c
+				c = chunk{seq: c.seq + 1, lines: make([]string, 0, chunkSize)}
+			}
+		}
+		if len(c.lines) > 0 {
+			chunks <- c
+		}
+		readErr = s.Err()
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			dec := encodings[enc].NewDecoder()
+			for c := range chunks {
+				c.recs = make([]record, 0, len(c.lines))
+				for i, raw := range c.lines {
+					if strings.TrimSpace(raw) == "" {
+						continue
+					}
+					var rec record
+					line, err := decodeString(dec, enc, raw)
+					if err != nil {
+						diags.add(diagnostic{
+							Severity: sevError,
+							Rule:     ruleEncoding,
+							File:     name,
+							Row:      c.seq*chunkSize + i + 1,
+							Value:    raw,
+							Message:  err.Error(),
+						})
+					} else if rec, err = decodeLine(line); err != nil {
+						e := err.(lineError)
+						diags.add(diagnostic{
+							Severity: sevError,
+							Rule:     e.rule,
+							File:     name,
+							Row:      c.seq*chunkSize + i + 1,
+							Column:   e.column,
+							Value:    line,
+							Message:  e.msg,
+						})
+					}
+					// A zero record marks a skipped line
+					c.recs = append(c.recs, rec)
+				}
+				decoded <- c
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(decoded)
+	}()
+
+	// Chunks are inserted in file order, so that the first
+	// occurrence of a repeated cedula wins, as it always has.  A
+	// checkpoint always falls between chunks.
+	next := 0
+	ready := make(map[int]chunk)
+	var lines int64
+	rows, last := 0, 0
+	for c := range decoded {
+		ready[c.seq] = c
+		for c, ok := ready[next]; ok; c, ok = ready[next] {
+			delete(ready, next)
+			next++
+			lines += int64(len(c.lines))
+			loaded := lines <= p.Lineas
+			for _, rec := range c.recs {
+				if err != nil {
+					break
+				}
+				switch {
+				case rec.persona.Id == 0:
+					l.skipped++
+				case loaded:
+					l.rows++
+					rows++
+				default:
+					rows++
+					err = l.add(rec)
+				}
+			}
+			if loaded {
+				bar.skipped()
+			} else if err == nil && rows-last >= checkpointRows {
+				last = rows
+				err = l.checkpoint(p, lines, rows, false)
+			}
+			bar.update(l.rows + len(l.pending))
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if readErr != nil {
+		return readErr
+	}
+
+	if lines < p.Lineas {
+		return fmt.Errorf("%d lines, but %d were loaded before", lines, p.Lineas)
+	}
+
+	return l.checkpoint(p, lines, rows, true)
+}
+
+func (l *loader) add(rec record) error {
+	l.pending = append(l.pending, rec)
+	if len(l.pending) < batchSize {
+		return nil
+	}
+	return l.flush()
+}
+
+// flush inserts the pending records.
+func (l *loader) flush() error {
+	n := len(l.pending)
+	if n == 0 {
+		return nil
+	}
+
+	personas, padron := l.personas, l.padron
+	if n < batchSize {
+		var err error
+		personas, err = l.trans.Prepare(insertStmt(l.target.personas, personasCols, n))
+		if err != nil {
+			return err
+		}
+		defer personas.Close()
+
+		padron, err = l.trans.Prepare(insertStmt(l.target.padron, padronCols, n))
+		if err != nil {
+			return err
+		}
+		defer padron.Close()
+	}
+
+	pargs := make([]interface{}, 0, n*len(personasCols))
+	jargs := make([]interface{}, 0, n*len(padronCols))
+	for _, rec := range l.pending {
+		p := &rec.persona
+		pargs = append(pargs, p.Id, p.Cedula, p.Expiracion, p.Nombre,
+			p.Apellido1, p.Apellido2, p.Genero)
+		jargs = append(jargs, l.eleccion, p.Id, rec.junta)
+	}
+
+	if _, err := personas.Exec(pargs...); err != nil {
+		return err
+	}
+	if _, err := padron.Exec(jargs...); err != nil {
+		return err
+	}
+
+	l.rows += n
+	l.pending = l.pending[:0]
+
+	return nil
+}
+
+func (l *loader) report() {
+	elapsed := time.Since(l.start)
+	log.Printf("I: %d rows in %s (%.0f rows/s)", l.rows,
+		elapsed-elapsed%time.Second, float64(l.rows)/elapsed.Seconds())
+}
+
+// finish removes duplicate assignments, merges the personas loaded and
+// recreates the deferred indexes.
+func (l *loader) finish() error {
+	l.personas.Close()
+	l.padron.Close()
+
+	type Duplicate struct {
+		PersonaId int64
+		Juntas    int
+	}
+
+	var dups []Duplicate
+
+	_, err := l.trans.Select(
+		&dups,
+		`SELECT
+			persona_id AS PersonaId,
+			COUNT(*) AS Juntas
+		FROM
+			`+l.target.padron+`
+		WHERE eleccion_id = ?
+		GROUP BY persona_id
+		HAVING COUNT(*) > 1`, l.eleccion)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range dups {
+		diags.add(diagnostic{
+			Severity: sevWarning,
+			Rule:     ruleDuplicate,
+			Column:   "cedula",
+			Value:    fmt.Sprint(d.PersonaId),
+			Message:  fmt.Sprintf("listed %d times, the first one is kept", d.Juntas),
+		})
+	}
+
+	// Like before, the first junta found for a persona wins
+	res, err := l.trans.Exec(`DELETE FROM `+l.target.padron+`
+		WHERE eleccion_id = ? AND rowid NOT IN
+			(SELECT MIN(rowid) FROM `+l.target.padron+`
+			WHERE eleccion_id = ? GROUP BY persona_id)`, l.eleccion, l.eleccion)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("W: Dropped %d duplicate padron entries", n)
+	}
+
+	if l.target.merge != "" {
+		_, err := l.trans.Exec(`INSERT OR REPLACE INTO ` + l.target.merge +
+			` (` + personasList + `) SELECT ` + personasList + ` FROM ` + l.target.personas)
+		if err == nil {
+			_, err = l.trans.Exec("DROP TABLE " + l.target.personas)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("I: Creating indexes")
+	for _, idx := range l.target.indexes {
+		if _, err := l.trans.Exec(idx.def); err != nil {
+			return fmt.Errorf("%s: %s", idx.name, err)
+		}
+	}
+
+	l.report()
+	if l.skipped > 0 {
+		log.Printf("W: Skipped %d invalid lines", l.skipped)
+	}
+
+	return nil
+}
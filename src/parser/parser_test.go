@@ -0,0 +1,450 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"cli"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// The import keeps its state in globals and exits on fatal errors, so
+// every case runs it in a process of its own: this test binary, asked
+// by the environment to run it instead.
+//
+// A case is a directory in testdata with the input files, an args file
+// with the arguments of the parser, a line per run in the same
+// database, and the golden files db.golden, a dump of the database
+// built, and report.golden, the JSON report of the last run.  A line
+// starting with sql: is instead run as SQL on the database, e.g. to
+// set what the scraper would between two imports.  One starting with
+// fail: is a run the parser must reject, and one starting with
+// interrupt: is stopped after its first checkpoint; neither may touch
+// the database.
+// Directories ending in .zip are zipped before the run.  Go test
+// -update rewrites the golden files with the current results.
+//
+// A case may also have a file named full, with the arguments of a
+// clean import of the inputs of the last run: the padron it builds
+// must be the one built by the runs in args.
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+const (
+	runParser       = "PARSER_TEST_RUN"
+	interruptParser = "PARSER_TEST_INTERRUPT"
+
+	// Exit status of an interrupted run
+	interrupted = 3
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runParser) != "" {
+		if os.Getenv(interruptParser) != "" {
+			afterCheckpoint = func() { os.Exit(interrupted) }
+		}
+		cli.Run(Import)
+		os.Exit(0)
+	}
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+// Contents of columns that change from run to run
+var volatile = map[string]bool{
+	"importaciones.fecha":  true,
+	"progreso.fecha":       true,
+	"schema_version.fecha": true,
+}
+
+// Tables about the imports rather than their result, left out of the
+// comparison with a clean import
+var bookkeeping = map[string]bool{
+	"cambios":        true,
+	"centros_ids":    true,
+	"importaciones":  true,
+	"progreso":       true,
+	"schema_version": true,
+}
+
+func TestGolden(t *testing.T) {
+	cases, err := filepath.Glob(filepath.Join("testdata", "*", "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("no cases in testdata")
+	}
+
+	for _, args := range cases {
+		dir := filepath.Dir(args)
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			testCase(t, dir)
+		})
+	}
+}
+
+func testCase(t *testing.T, dir string) {
+	tmp, err := ioutil.TempDir("", "parser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := copyInputs(dir, tmp); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := runLines(t, tmp, strings.Split(string(b), "\n"))
+
+	db, err := dumpDb(filepath.Join(tmp, "padron.db"), nil)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, out)
+	}
+	report, err := dumpReport(filepath.Join(tmp, "report.json"))
+	if err != nil {
+		t.Fatalf("%s\n%s", err, out)
+	}
+
+	golden(t, filepath.Join(dir, "db.golden"), db)
+	golden(t, filepath.Join(dir, "report.golden"), report)
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "full"))
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean, err := ioutil.TempDir("", "parser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(clean)
+	if err := copyInputs(dir, clean); err != nil {
+		t.Fatal(err)
+	}
+	out = runLines(t, clean, strings.Split(string(b), "\n"))
+
+	got, err := dumpDb(filepath.Join(tmp, "padron.db"), bookkeeping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := dumpDb(filepath.Join(clean, "padron.db"), bookkeeping)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, out)
+	}
+	compare(t, "database (clean import on the right)", got, want)
+}
+
+// runLines runs the lines of an args file in tmp and returns the
+// output of the last run.
+func runLines(t *testing.T, tmp string, lines []string) []byte {
+	var out []byte
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "sql:") {
+			if err := execSQL(filepath.Join(tmp, "padron.db"), line[len("sql:"):]); err != nil {
+				t.Fatalf("%s: %s", line, err)
+			}
+			continue
+		}
+
+		status := 0
+		env := append(os.Environ(), runParser+"=1")
+		switch {
+		case strings.HasPrefix(line, "fail:"):
+			status, line = 1, line[len("fail:"):]
+		case strings.HasPrefix(line, "interrupt:"):
+			status, line = interrupted, line[len("interrupt:"):]
+			env = append(env, interruptParser+"=1")
+		}
+		before, _ := ioutil.ReadFile(filepath.Join(tmp, "padron.db"))
+
+		// Whatever $PADRON_DB and $PADRON_LOG say
+		args := append([]string{"-db", "padron.db", "-log", "", "-report-json", "report.json"},
+			strings.Fields(line)...)
+
+		cmd := exec.Command(os.Args[0], args...)
+		cmd.Dir = tmp
+		cmd.Env = env
+		var err error
+		out, err = cmd.CombinedOutput()
+		got := 0
+		if e, ok := err.(*exec.ExitError); ok {
+			got = e.ExitCode()
+		} else if err != nil {
+			t.Fatalf("parser %s: %s", strings.Join(args, " "), err)
+		}
+		if got != status {
+			t.Fatalf("parser %s: exit status %d, want %d\n%s",
+				strings.Join(args, " "), got, status, out)
+		}
+
+		if status != 0 {
+			after, _ := ioutil.ReadFile(filepath.Join(tmp, "padron.db"))
+			if !bytes.Equal(before, after) {
+				t.Fatalf("parser %s: changed the database\n%s", strings.Join(args, " "), out)
+			}
+		}
+	}
+	return out
+}
+
+// execSQL runs query on the database at path.
+func execSQL(path, query string) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(query)
+	return err
+}
+
+// copyInputs copies the files in dir to tmp, zipping its directories.
+func copyInputs(dir, tmp string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		src, dst := filepath.Join(dir, fi.Name()), filepath.Join(tmp, fi.Name())
+		if fi.IsDir() {
+			err = zipDir(src, dst)
+		} else {
+			err = copyFile(src, dst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zipDir writes the files in dir to the ZIP file path, always with the
+// same bytes: the SHA-256 of the inputs is in the results.  They are
+// stored, deflated ones would change with the compressor of each Go
+// release.
+func zipDir(dir, path string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for _, fi := range files {
+		h := &zip.FileHeader{Name: fi.Name(), Method: zip.Store}
+		h.SetModTime(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+		w, err := z.CreateHeader(h)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := z.Close(); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// dumpDb returns the rows of every table of the database at path but
+// those in skip, sorted, a line per row.
+func dumpDb(path string, skip map[string]bool) ([]byte, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var tables []string
+	rows, err := db.Query(`SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, table := range tables {
+		if skip[table] {
+			continue
+		}
+		lines, cols, err := dumpTable(db, table)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", table, err)
+		}
+		fmt.Fprintf(&buf, "%s (%s)\n", table, strings.Join(cols, ", "))
+		for _, l := range lines {
+			fmt.Fprintf(&buf, "\t%s\n", l)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func dumpTable(db *sql.DB, table string) ([]string, []string, error) {
+	rows, err := db.Query("SELECT * FROM " + table)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var lines []string
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, nil, err
+		}
+		fields := make([]string, len(cols))
+		for i, v := range values {
+			switch v := v.(type) {
+			case nil:
+				fields[i] = "NULL"
+			case []byte:
+				fields[i] = fmt.Sprintf("%q", v)
+			case string:
+				fields[i] = fmt.Sprintf("%q", v)
+			default:
+				fields[i] = fmt.Sprint(v)
+			}
+			if volatile[table+"."+cols[i]] {
+				fields[i] = "*"
+			}
+		}
+		lines = append(lines, strings.Join(fields, "|"))
+	}
+	sort.Strings(lines)
+	return lines, cols, rows.Err()
+}
+
+type byPlace []diagnostic
+
+func (s byPlace) Len() int      { return len(s) }
+func (s byPlace) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byPlace) Less(i, j int) bool {
+	a, b := s[i], s[j]
+	switch {
+	case a.File != b.File:
+		return a.File < b.File
+	case a.Sheet != b.Sheet:
+		return a.Sheet < b.Sheet
+	case a.Row != b.Row:
+		return a.Row < b.Row
+	case a.Rule != b.Rule:
+		return a.Rule < b.Rule
+	case a.Column != b.Column:
+		return a.Column < b.Column
+	case a.Value != b.Value:
+		return a.Value < b.Value
+	}
+	return a.Message < b.Message
+}
+
+// dumpReport returns the JSON report at path indented, its diagnostics
+// sorted: the padron is checked by several workers.
+func dumpReport(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r report
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	sort.Sort(byPlace(r.Diagnostics))
+
+	b, err = json.MarshalIndent(&r, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// golden compares got with the golden file at path, or writes it there
+// with -update.
+func golden(t *testing.T, path string, got []byte) {
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s, run go test -update to create it", err)
+	}
+	compare(t, path, got, want)
+}
+
+// compare reports the first line where got and want, the contents of
+// what, differ.
+func compare(t *testing.T, what string, got, want []byte) {
+	if bytes.Equal(got, want) {
+		return
+	}
+
+	g := strings.Split(string(got), "\n")
+	w := strings.Split(string(want), "\n")
+	for i := 0; i < len(g) || i < len(w); i++ {
+		var gl, wl string
+		if i < len(g) {
+			gl = g[i]
+		}
+		if i < len(w) {
+			wl = w[i]
+		}
+		if gl != wl {
+			t.Errorf("%s:%d: got\n\t%s\nwant\n\t%s", what, i+1, gl, wl)
+			return
+		}
+	}
+}
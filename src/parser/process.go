@@ -0,0 +1,408 @@
+package parser
+
+import (
+	"cli"
+	"log"
+	"model"
+	"os"
+
+	"github.com/coopernurse/gorp"
+)
+
+// Import builds the database from the files published by the TSE.
+var Import = &cli.Command{
+	Name:      "import",
+	UsageLine: "file...",
+	Short:     "build the database from the files published by the TSE",
+	Long: `
+Each file is one of the inputs published by the TSE, recognized by its
+content:
+
+  padron_completo.zip    padron and Distelec.txt, as downloaded
+  PADRON_COMPLETO.txt    padron, one line per elector
+  Distelec.txt           provincias, cantones and distritos
+  *.xlsx, *.ods, *.csv   centros de votacion or juntas receptoras
+`,
+}
+
+var (
+	formato = Import.Flag.String("formato", "",
+		"election year of the spreadsheet layout (default: any known)")
+	alias = Import.Flag.String("alias", "",
+		"JSON file with additional spreadsheet header texts per election year")
+	maxErrors = Import.Flag.Int("max-errors", -1,
+		"exit with status 1 if more rows than this are rejected (-1 means no limit)")
+	strict = Import.Flag.Bool("strict", false,
+		"exit with status 1 on any error or warning")
+	fresh = Import.Flag.Bool("fresh", false,
+		"build the database from scratch instead of updating a copy of the current one")
+	keep      = Import.Flag.Int("keep", 3, "previous databases to keep, as <db>.1 to <db>.N")
+	maxShrink = Import.Flag.Float64("max-shrink", 0.1,
+		"largest fraction of personas that may disappear from the previous database")
+	incremental = Import.Flag.Bool("incremental", false,
+		"apply only the changes to the padron of the previous database, recording them in cambios")
+	eleccion = Import.Flag.String("eleccion", "",
+		"election the files belong to, e.g. 2018 (default: the latest one in the database)")
+	fecha   = Import.Flag.String("fecha", "", "election day (YYYY-MM-DD), to set it")
+	restart = Import.Flag.Bool("restart", false,
+		"start the build over instead of resuming an interrupted import")
+)
+
+func init() {
+	Import.Run = runImport
+
+	Import.Flag.StringVar(&reportJSON, "report-json", "",
+		"write the validation report as JSON to this file")
+	Import.Flag.StringVar(&reportHTML, "report-html", "",
+		"write the validation report as HTML to this file")
+	Import.Flag.StringVar(&textEncoding, "encoding", "auto",
+		"encoding of the padron and Distelec.txt: auto, iso-8859-15, windows-1252 or utf-8")
+	Import.Flag.IntVar(&checkpointRows, "checkpoint", checkpointRows,
+		"padron rows loaded between commits an interrupted import can resume from")
+}
+
+func runImport(cmd *cli.Command, args []string) {
+	if len(args) == 0 {
+		cmd.Fail()
+	}
+
+	if _, ok := encodings[textEncoding]; !ok && textEncoding != "auto" {
+		log.Fatalf(`E: Unknown encoding %q. Abort.`, textEncoding)
+	}
+
+	headers, err := loadLayout(*formato, *alias)
+	if err != nil {
+		log.Fatalf(`E: Can't load spreadsheet layout: %s. Abort.`, err)
+	}
+
+	var all inputs
+	sums := make(map[string]string)
+	for _, fn := range args {
+		found, err := classify(fn, headers)
+		if err != nil {
+			log.Fatalf(`E: Can't read input: %s. Abort.`, err)
+		}
+		if len(found) == 0 {
+			log.Fatalf(`E: %s: unrecognized input. Abort.`, fn)
+		}
+		for _, in := range found {
+			if in.kind == kindUnknown {
+				log.Fatalf(`E: %s: unrecognized input. Abort.`, in)
+			}
+			log.Printf("I: %s: %s", in, in.kind)
+			all.add(in)
+
+			if _, ok := sums[in.path]; !ok {
+				sums[in.path], err = hashFile(in.path)
+				if err != nil {
+					log.Fatalf(`E: Can't read input: %s. Abort.`, err)
+				}
+			}
+			diags.input(in, sums[in.path])
+		}
+	}
+
+	padron := processInput(&all, headers)
+
+	before, err := countPadron(cli.Db, *eleccion)
+	if err != nil {
+		log.Fatalf(`E: Can't read %s: %s. Abort.`, cli.Db, err)
+	}
+
+	// A previous run with the same inputs may have been interrupted
+	var imp *model.Importacion
+	tmp := cli.Db + ".new"
+	if !*restart {
+		imp, err = resumable(tmp, *eleccion, *incremental)
+		if err != nil {
+			log.Printf("W: Can't resume the import in %s, starting over: %s", tmp, err)
+		}
+	}
+
+	if imp == nil {
+		tmp, err = startBuild(cli.Db, *fresh)
+		if err != nil {
+			log.Fatalf(`E: Can't prepare %s: %s. Abort.`, cli.Db, err)
+		}
+		log.Printf("I: Building %s", tmp)
+	} else {
+		log.Printf("I: Resuming the import in %s", tmp)
+	}
+
+	if err := migrate(tmp); err != nil {
+		log.Fatalf(`E: Can't migrate %s: %s. Abort.`, tmp, err)
+	}
+
+	dbmap, err := model.OpenDb(tmp)
+	if err != nil {
+		log.Fatalf(`E: Can't initialize database: %s. Abort.`, err)
+	}
+
+	trans, err := dbmap.Begin()
+	if err != nil {
+		log.Fatalf(`E: Can't initialize transaction: %s. Abort.`, err)
+	}
+
+	// The padron goes to the staging tables of an incremental import
+	target, staging := fullLoad, *incremental && len(all.padron) > 0
+	if staging {
+		target = stagingLoad
+	}
+
+	var e *model.Eleccion
+	if imp == nil {
+		e, err = getEleccion(trans, *eleccion, *fecha)
+		if err != nil {
+			log.Fatalf(`E: Can't get the election: %s. Abort.`, err)
+		}
+
+		imp, err = startImport(trans, e.Id)
+		if err != nil {
+			log.Fatalf(`E: Can't record the import: %s. Abort.`, err)
+		}
+
+		// Committed with the first checkpoint
+		loadPlaces(trans, padron, e.Id)
+		clearPadron(trans, e.Id, staging, len(all.padron) > 0)
+	} else {
+		e = &model.Eleccion{}
+		err := trans.SelectOne(e, "SELECT * FROM elecciones WHERE id = ?", imp.EleccionId)
+		if err != nil {
+			log.Fatalf(`E: Can't get the election of the import: %s. Abort.`, err)
+		}
+	}
+	log.Printf("I: Importing into election %s", e.Nombre)
+
+	l, err := newLoader(dbmap, trans, target, e.Id)
+	if err != nil {
+		log.Fatalf(`E: Can't prepare padron load: %s. Abort.`, err)
+	}
+
+	for _, in := range all.padron {
+		p, err := getProgreso(l.trans, imp, in, sums[in.path])
+		if err != nil {
+			log.Fatalf(`E: Can't get the progress through %s: %s. Abort.`, in, err)
+		}
+
+		r, err := in.open()
+		if err != nil {
+			log.Fatalf(`E: Can't open %s: %s. Abort.`, in, err)
+		}
+
+		switch {
+		case p.Terminado:
+			log.Printf("I: %s is already loaded, checking it", in)
+		case p.Lineas > 0:
+			log.Printf("I: Loading %s from line %d", in, p.Lineas+1)
+		default:
+			log.Printf("I: Loading %s", in)
+		}
+
+		if err := l.load(in.String(), r, in.size(), p); err != nil {
+			log.Fatalf(`E: Can't load %s: %s. Abort.`, in, err)
+		}
+
+		r.Close()
+	}
+
+	// The load committed its checkpoints, the rest goes in its
+	// last transaction
+	trans = l.trans
+
+	if err := l.finish(); err != nil {
+		log.Fatalf(`E: Can't finish padron load: %s. Abort.`, err)
+	}
+
+	if staging {
+		if err := applyChanges(trans, imp); err != nil {
+			log.Fatalf(`E: Can't apply the padron changes: %s. Abort.`, err)
+		}
+	}
+
+	if len(all.padron) > 0 && len(padron.JuntaCentro) > 0 {
+		if err := padron.CheckElectores(trans, e.Id); err != nil {
+			log.Fatalf(`E: Can't count electores per junta: %s. Abort.`, err)
+		}
+	}
+
+	if err := finishImport(trans, imp); err != nil {
+		log.Fatalf(`E: Can't record the import: %s. Abort.`, err)
+	}
+
+	diags.write()
+	log.Printf("I: %d errors, %d warnings", diags.Errors, diags.Warnings)
+
+	// A rejected import isn't finished, the checkpoints committed
+	// before stay for a run with another policy to resume from
+	if diags.failed(*maxErrors, *strict) {
+		trans.Rollback()
+		dbmap.Db.Close()
+		log.Printf("E: Import rejected by the validation policy, left in %s", tmp)
+		os.Exit(1)
+	}
+
+	if err := trans.Commit(); err != nil {
+		log.Fatalf(`E: Can't commit: %s. Abort.`, err)
+	}
+
+	err = checkBuild(dbmap, e.Id, before, *maxShrink)
+	dbmap.Db.Close()
+	if err != nil {
+		log.Fatalf(`E: %s failed the checks, left in place: %s. Abort.`, tmp, err)
+	}
+
+	if err := swapIn(tmp, cli.Db, *keep); err != nil {
+		log.Fatalf(`E: Can't move %s into place: %s. Abort.`, tmp, err)
+	}
+	log.Printf("I: %s is ready", cli.Db)
+}
+
+// loadPlaces adds the provincias, cantones, distritos, distritos
+// electorales, centros and juntas of padron, the latter two into the
+// election eleccionId.
+func loadPlaces(trans *gorp.Transaction, padron *Padron, eleccionId int64) {
+	for id, nombre := range padron.Provincias {
+		provincia := model.Provincia{
+			Id:     toInt64(id),
+			Nombre: nombre,
+		}
+		_, err := getOrInsert(trans, &provincia)
+		if err != nil {
+			log.Printf("Can't get or insert provincia %d: %s",
+				provincia.Id, err)
+		}
+	}
+
+	for id, nombre := range padron.Cantones {
+		canton := model.Canton{
+			Id:          toInt64(id),
+			Nombre:      nombre,
+			ProvinciaId: toInt64(id[0:1]),
+		}
+		_, err := getOrInsert(trans, &canton)
+		if err != nil {
+			log.Printf("Can't get or insert canton %d: %s",
+				canton.Id, err)
+		}
+	}
+
+	for id, nombre := range padron.Distritos {
+		distrito := model.Distrito{
+			Id:       toInt64(id),
+			Nombre:   nombre,
+			CantonId: toInt64(id[0:3]),
+		}
+		_, err := getOrInsert(trans, &distrito)
+		if err != nil {
+			log.Printf("Can't get or insert distrito %d: %s",
+				distrito.Id, err)
+		}
+	}
+
+	for id, nombre := range padron.DEs {
+		distrito := model.DistritoElectoral{
+			Id:         toInt64(id),
+			Nombre:     nombre,
+			DistritoId: toInt64(id[0:6]),
+		}
+		_, err := getOrInsert(trans, &distrito)
+		if err != nil {
+			log.Printf("Can't get or insert distrito electoral %d: %s",
+				distrito.Id, err)
+		}
+	}
+
+	if len(padron.Centros) > 0 && len(padron.Juntas) == 0 {
+		log.Println("W: Centros can't be loaded without the juntas list")
+		padron.Centros = nil
+	}
+
+	// Rows listing the same centro get the same id
+	centros := make(map[int64]*model.Centro)
+	centroIds := make(map[int]int64)
+	for row, centro := range padron.Centros {
+		deId := padron.Juntas[centro.JuntaStartId].DEId
+		c := &model.Centro{
+			EleccionId:          eleccionId,
+			Id:                  centroId(deId, centro.Tipo, centro.Nombre),
+			Tipo:                centro.Tipo,
+			Nombre:              centro.Nombre,
+			DistritoElectoralId: toInt64(deId),
+		}
+		centros[c.Id] = c
+		centroIds[row] = c.Id
+	}
+
+	ranges := make(map[int64]juntaRange)
+	for jid, row := range padron.JuntaCentro {
+		id := centroIds[row]
+		r := ranges[id]
+		r.add(centros[id].DistritoElectoralId, int64(jid))
+		ranges[id] = r
+	}
+
+	if err := replaceCentros(trans, eleccionId, centros, ranges); err != nil {
+		log.Fatalf(`E: Can't replace old centro ids: %s. Abort.`, err)
+	}
+
+	for _, c := range centros {
+		_, err := getOrInsert(trans, c)
+		if err != nil {
+			log.Printf("Can't get or insert centro %d: %s", c.Id, err)
+		}
+	}
+
+	for jid, row := range padron.JuntaCentro {
+		j := model.Junta{
+			EleccionId: eleccionId,
+			Id:         int64(jid),
+			CentroId:   centroIds[row],
+			Electores:  int64(padron.Juntas[jid].Electores),
+		}
+		if err := putJunta(trans, &j); err != nil {
+			log.Printf("Can't insert or update junta %d: %s", j.Id, err)
+		}
+	}
+
+	// Centros the new list no longer has are left without juntas
+	if len(padron.JuntaCentro) > 0 {
+		res, err := trans.Exec(`DELETE FROM centros WHERE eleccion_id = ?
+			AND id NOT IN (SELECT centro_id FROM juntas WHERE eleccion_id = ?)`,
+			eleccionId, eleccionId)
+		if err != nil {
+			log.Fatalf(`E: Can't remove old centros: %s. Abort.`, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			log.Printf("I: Removed %d centros without juntas", n)
+		}
+	}
+}
+
+// clearPadron prepares the padron of the election eleccionId for the
+// load: into the staging tables, or replacing it in full if there is a
+// new one.  The personas of a full load go to personas_carga.
+func clearPadron(trans *gorp.Transaction, eleccionId int64, staging, replace bool) {
+	if staging {
+		if err := createStaging(trans); err != nil {
+			log.Fatalf(`E: Can't create the staging tables: %s. Abort.`, err)
+		}
+		return
+	}
+	if err := createCarga(trans); err != nil {
+		log.Fatalf(`E: Can't create personas_carga: %s. Abort.`, err)
+	}
+	if !replace {
+		return
+	}
+
+	// The padron is loaded in full, personas only kept by other
+	// elections go too
+	_, err := trans.Exec("DELETE FROM padron WHERE eleccion_id = ?", eleccionId)
+	if err == nil {
+		_, err = trans.Exec(`DELETE FROM personas
+			WHERE id NOT IN (SELECT persona_id FROM padron)`)
+	}
+	if err != nil {
+		log.Fatalf(`E: Can't empty the padron: %s. Abort.`, err)
+	}
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	barWidth = 30
+
+	// How often the bar is redrawn on a terminal
+	drawInterval = 200 * time.Millisecond
+)
+
+// countingReader counts the bytes read from r.  n may be read by
+// other goroutines with atomic.LoadInt64.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+// progress shows how far the load of a file got, with its ETA: a bar
+// redrawn in place if stderr is a terminal, a log line every
+// progressInterval otherwise.
+type progress struct {
+	name string
+	size int64 // bytes, zero if unknown
+	in   *countingReader
+
+	// The ETA is estimated from the bytes loaded since start, lines
+	// skipped when resuming go much faster
+	start      time.Time
+	startBytes int64
+
+	last time.Time
+	tty  bool
+}
+
+func newProgress(name string, size int64, in *countingReader) *progress {
+	now := time.Now()
+	fi, err := os.Stderr.Stat()
+	return &progress{
+		name:  name,
+		size:  size,
+		in:    in,
+		start: now,
+		last:  now,
+		tty:   err == nil && fi.Mode()&os.ModeCharDevice != 0,
+	}
+}
+
+// skipped restarts the ETA estimate, the bytes read so far were only
+// skipped.
+func (p *progress) skipped() {
+	p.start = time.Now()
+	p.startBytes = atomic.LoadInt64(&p.in.n)
+}
+
+// update shows the progress, rows being the rows loaded so far, if
+// it's time to.
+func (p *progress) update(rows int) {
+	now := time.Now()
+	switch {
+	case p.tty && now.Sub(p.last) >= drawInterval:
+		fmt.Fprintf(os.Stderr, "\x1b[K%s\r", p.line(rows, now))
+	case !p.tty && now.Sub(p.last) >= progressInterval:
+		log.Printf("I: %s", p.line(rows, now))
+	default:
+		return
+	}
+	p.last = now
+}
+
+// done clears the bar.
+func (p *progress) done() {
+	if p.tty {
+		fmt.Fprint(os.Stderr, "\x1b[K")
+	}
+}
+
+func (p *progress) line(rows int, now time.Time) string {
+	s := fmt.Sprintf("%s: %d rows", p.name, rows)
+	if p.size <= 0 {
+		return s
+	}
+
+	read := atomic.LoadInt64(&p.in.n)
+	frac := float64(read) / float64(p.size)
+	if frac > 1 {
+		frac = 1
+	}
+	n := int(frac * barWidth)
+	s = fmt.Sprintf("[%s%s] %3.0f%% %s", strings.Repeat("=", n),
+		strings.Repeat(" ", barWidth-n), frac*100, s)
+
+	elapsed := now.Sub(p.start)
+	if moved := read - p.startBytes; moved > 0 && elapsed >= time.Second {
+		eta := time.Duration(float64(elapsed) * float64(p.size-read) / float64(moved))
+		if eta < 0 {
+			eta = 0
+		}
+		s += ", ETA " + (eta - eta%time.Second).String()
+	}
+	return s
+}
@@ -0,0 +1,304 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type severity string
+
+const (
+	// The row was dropped
+	sevError severity = "error"
+	// The row was kept, but disagrees with something else
+	sevWarning severity = "warning"
+)
+
+// Validation rules a diagnostic can be about.
+const (
+	ruleMissingColumn   = "missing-column"
+	ruleNotANumber      = "not-a-number"
+	ruleRangeTotal      = "range-total"
+	ruleCodigoLength    = "codigo-length"
+	ruleBadFormat       = "bad-format"
+	ruleDEDistrito      = "de-distrito"
+	ruleFieldCount      = "field-count"
+	ruleInvalidId       = "invalid-id"
+	ruleEncoding        = "encoding"
+	ruleDuplicate       = "duplicate-cedula"
+	ruleConflictingName = "conflicting-name"
+	ruleDistelec        = "distelec-mismatch"
+	ruleDEMismatch      = "de-mismatch"
+	ruleDuplicateJunta  = "duplicate-junta"
+	ruleRangeOrder      = "range-order"
+	ruleRangeOverlap    = "range-overlap"
+	ruleRangeMissing    = "range-missing"
+	ruleOrphanJunta     = "orphan-junta"
+	ruleElectores       = "electores-count"
+	ruleUnknownJunta    = "unknown-junta"
+)
+
+// diagnostic describes a problem found in the input.  Row is 1-based,
+// 0 when the problem is not about a single row.
+type diagnostic struct {
+	Severity severity `json:"severity"`
+	Rule     string   `json:"rule"`
+	File     string   `json:"file,omitempty"`
+	Sheet    string   `json:"sheet,omitempty"`
+	Row      int      `json:"row,omitempty"`
+	Column   string   `json:"column,omitempty"`
+	Value    string   `json:"value,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (d diagnostic) String() string {
+	var where []string
+	if d.File != "" {
+		where = append(where, d.File)
+	}
+	if d.Sheet != "" {
+		where = append(where, fmt.Sprintf("sheet %q", d.Sheet))
+	}
+	if d.Row > 0 {
+		where = append(where, fmt.Sprintf("row %d", d.Row))
+	}
+	if d.Column != "" {
+		where = append(where, d.Column)
+	}
+
+	s := d.Message
+	if len(where) > 0 {
+		s = strings.Join(where, ": ") + ": " + s
+	}
+	if d.Value != "" {
+		s += fmt.Sprintf(": %q", d.Value)
+	}
+	return s
+}
+
+// origin is the row some data was read from.
+type origin struct {
+	file  string
+	sheet string
+	row   int
+}
+
+func (o origin) diag(sev severity, rule, column, value, msg string) diagnostic {
+	return diagnostic{
+		Severity: sev,
+		Rule:     rule,
+		File:     o.file,
+		Sheet:    o.sheet,
+		Row:      o.row,
+		Column:   column,
+		Value:    value,
+		Message:  msg,
+	}
+}
+
+// Only the first diagnostics of each rule are kept, the rest are just
+// counted: a broken padron file would fill the memory otherwise.
+const maxPerRule = 10000
+
+type ruleCount struct {
+	Rule     string   `json:"rule"`
+	Severity severity `json:"severity"`
+	Count    int      `json:"count"`
+}
+
+type reportInput struct {
+	File     string `json:"file"`
+	Kind     string `json:"kind"`
+	Encoding string `json:"encoding,omitempty"`
+	Sha256   string `json:"sha256"`
+}
+
+// report collects the diagnostics of an import.  It is safe for
+// concurrent use.
+type report struct {
+	mu          sync.Mutex
+	Inputs      []reportInput `json:"inputs"`
+	Errors      int           `json:"errors"`
+	Warnings    int           `json:"warnings"`
+	Rules       []ruleCount   `json:"rules"`
+	Diagnostics []diagnostic  `json:"diagnostics"`
+
+	counts map[string]*ruleCount
+}
+
+var diags = &report{counts: make(map[string]*ruleCount)}
+
+// input records in, sum being the SHA-256 of its file.
+func (r *report) input(in input, sum string) {
+	r.mu.Lock()
+	r.Inputs = append(r.Inputs, reportInput{
+		File:   in.String(),
+		Kind:   in.kind.String(),
+		Sha256: sum,
+	})
+	r.mu.Unlock()
+}
+
+// encoding records the text encoding of the input named file.
+func (r *report) encoding(file, enc string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.Inputs {
+		if r.Inputs[i].File == file {
+			r.Inputs[i].Encoding = enc
+		}
+	}
+}
+
+// add records d and logs it.
+func (r *report) add(d diagnostic) {
+	if d.Severity == sevError {
+		log.Printf("W: %s", d)
+	} else {
+		log.Printf("W: %s (kept)", d)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.counts[d.Rule]
+	if !ok {
+		c = &ruleCount{Rule: d.Rule, Severity: d.Severity}
+		r.counts[d.Rule] = c
+	}
+	c.Count++
+
+	if d.Severity == sevError {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+
+	if c.Count <= maxPerRule {
+		r.Diagnostics = append(r.Diagnostics, d)
+	}
+}
+
+// fatal records d, writes the reports and aborts.
+func (r *report) fatal(d diagnostic) {
+	r.add(d)
+	r.write()
+	log.Fatalf(`E: %s. Abort.`, d)
+}
+
+func (r *report) summarize() {
+	r.Rules = r.Rules[:0]
+	for _, c := range r.counts {
+		r.Rules = append(r.Rules, *c)
+	}
+	sort.Sort(byCount(r.Rules))
+}
+
+type byCount []ruleCount
+
+func (s byCount) Len() int      { return len(s) }
+func (s byCount) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byCount) Less(i, j int) bool {
+	if s[i].Count != s[j].Count {
+		return s[i].Count > s[j].Count
+	}
+	return s[i].Rule < s[j].Rule
+}
+
+// Paths of the reports, set from the command line.
+var reportJSON, reportHTML string
+
+// write writes the JSON and HTML reports requested on the command
+// line.
+func (r *report) write() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.summarize()
+
+	if reportJSON != "" {
+		if err := writeFile(reportJSON, func(f *os.File) error {
+			enc := json.NewEncoder(f)
+			return enc.Encode(r)
+		}); err != nil {
+			log.Printf("W: Can't write report: %s", err)
+		}
+	}
+
+	if reportHTML != "" {
+		if err := writeFile(reportHTML, func(f *os.File) error {
+			return reportTmpl.Execute(f, r)
+		}); err != nil {
+			log.Printf("W: Can't write report: %s", err)
+		}
+	}
+}
+
+func writeFile(path string, fn func(*os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// failed tells whether the import must be rejected: more than
+// maxErrors errors, a negative value meaning no limit, or in strict
+// mode any diagnostic at all.
+func (r *report) failed(maxErrors int, strict bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if strict {
+		return r.Errors+r.Warnings > 0
+	}
+	return maxErrors >= 0 && r.Errors > maxErrors
+}
+
+var reportTmpl = template.Must(template.New("report").Parse(`<!doctype html>
+<html lang="es">
+<head>
+  <meta charset="utf-8">
+  <title>Importación del padrón</title>
+  <style>
+    body { font-family: sans-serif; font-size: 14px; }
+    table { border-collapse: collapse; margin-bottom: 16px; }
+    th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: left; }
+    .error { color: #a94442; }
+    .warning { color: #8a6d3b; }
+  </style>
+</head>
+<body>
+  <h1>Importación del padrón</h1>
+  <h2>Archivos</h2>
+  <table>
+    <tr><th>Archivo</th><th>Tipo</th><th>Codificación</th></tr>
+    {{range .Inputs}}<tr><td>{{.File}}</td><td>{{.Kind}}</td><td>{{.Encoding}}</td></tr>
+    {{end}}
+  </table>
+  <h2>Resumen</h2>
+  <p>{{.Errors}} errores, {{.Warnings}} advertencias.</p>
+  <table>
+    <tr><th>Regla</th><th>Severidad</th><th>Cantidad</th></tr>
+    {{range .Rules}}<tr class="{{.Severity}}"><td>{{.Rule}}</td><td>{{.Severity}}</td><td>{{.Count}}</td></tr>
+    {{end}}
+  </table>
+  <h2>Detalle</h2>
+  <table>
+    <tr><th>Severidad</th><th>Regla</th><th>Archivo</th><th>Hoja</th><th>Fila</th><th>Columna</th><th>Valor</th><th>Mensaje</th></tr>
+    {{range .Diagnostics}}<tr class="{{.Severity}}"><td>{{.Severity}}</td><td>{{.Rule}}</td><td>{{.File}}</td><td>{{.Sheet}}</td><td>{{if .Row}}{{.Row}}{{end}}</td><td>{{.Column}}</td><td>{{.Value}}</td><td>{{.Message}}</td></tr>
+    {{end}}
+  </table>
+</body>
+</html>
+`))
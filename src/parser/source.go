@@ -0,0 +1,322 @@
+package parser
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+)
+
+// sheet is a table of cell texts.
+type sheet struct {
+	name string
+	rows [][]string
+}
+
+// rowSource is a spreadsheet the centros or juntas lists can be read
+// from, whatever its file format.
+type rowSource interface {
+	Sheets() ([]sheet, error)
+}
+
+const odsMimetype = "application/vnd.oasis.opendocument.spreadsheet"
+
+// openRows returns the row source for the spreadsheet at path: XLSX,
+// ODS or, if it is not a ZIP file, CSV.
+func openRows(path string) (rowSource, error) {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		if err == zip.ErrFormat {
+			return csvSource(path), nil
+		}
+		return nil, err
+	}
+	defer z.Close()
+
+	for _, e := range z.File {
+		switch e.Name {
+		case "[Content_Types].xml":
+			return xlsxSource(path), nil
+		case "mimetype":
+			rc, err := e.Open()
+			if err != nil {
+				return nil, err
+			}
+			b, err := ioutil.ReadAll(io.LimitReader(rc, 128))
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(string(b)) == odsMimetype {
+				return odsSource(path), nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%s: not a spreadsheet", path)
+}
+
+type xlsxSource string
+
+func (path xlsxSource) Sheets() ([]sheet, error) {
+	x, err := xlsx.OpenFile(string(path))
+	if err != nil {
+		return nil, err
+	}
+
+	sheets := make([]sheet, 0, len(x.Sheets))
+	for _, s := range x.Sheets {
+		rows := make([][]string, 0, len(s.Rows))
+		for _, r := range s.Rows {
+			row := make([]string, 0, len(r.Cells))
+			for _, c := range r.Cells {
+				row = append(row, c.Value)
+			}
+			rows = append(rows, row)
+		}
+		sheets = append(sheets, sheet{s.Name, rows})
+	}
+	return sheets, nil
+}
+
+// odsSource reads the content.xml of an OpenDocument spreadsheet.
+type odsSource string
+
+const (
+	odsTable  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsText   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	odsOffice = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+)
+
+// Spreadsheet applications pad sheets with huge runs of repeated empty
+// cells and rows, those are not materialized.
+const odsMaxRepeat = 1024
+
+func (path odsSource) Sheets() ([]sheet, error) {
+	z, err := zip.OpenReader(string(path))
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	for _, e := range z.File {
+		if e.Name != "content.xml" {
+			continue
+		}
+		rc, err := e.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		sheets, err := readODS(rc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		return sheets, nil
+	}
+
+	return nil, fmt.Errorf("%s: no content.xml", path)
+}
+
+func attr(e xml.StartElement, space, local string) string {
+	for _, a := range e.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func repeat(e xml.StartElement, local string) int {
+	n, err := strconv.Atoi(attr(e, odsTable, local))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+func readODS(r io.Reader) ([]sheet, error) {
+	var (
+		sheets []sheet
+		cur    *sheet
+		row    []string
+		rowRep int
+		empty  int // empty rows not yet added to cur
+
+		inCell bool
+		cell   bytes.Buffer
+		value  string
+		colRep int
+		paras  int
+	)
+
+	d := xml.NewDecoder(r)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == odsTable && t.Name.Local == "table":
+				sheets = append(sheets, sheet{name: attr(t, odsTable, "name")})
+				cur = &sheets[len(sheets)-1]
+				empty = 0
+			case t.Name.Space == odsTable && t.Name.Local == "table-row":
+				row = nil
+				rowRep = repeat(t, "number-rows-repeated")
+			case t.Name.Space == odsTable &&
+				(t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell = true
+				cell.Reset()
+				paras = 0
+				colRep = repeat(t, "number-columns-repeated")
+				// Numbers are taken from the value, the text
+				// may be formatted
+				value = ""
+				if attr(t, odsOffice, "value-type") == "float" {
+					value = attr(t, odsOffice, "value")
+				}
+			case inCell && t.Name.Space == odsText && t.Name.Local == "p":
+				if paras > 0 {
+					cell.WriteByte('\n')
+				}
+				paras++
+			case inCell && t.Name.Space == odsText && t.Name.Local == "s":
+				n, err := strconv.Atoi(attr(t, odsText, "c"))
+				if err != nil || n < 1 {
+					n = 1
+				}
+				cell.WriteString(strings.Repeat(" ", n))
+			case inCell && t.Name.Space == odsText && t.Name.Local == "tab":
+				cell.WriteByte('\t')
+			}
+
+		case xml.CharData:
+			if inCell && paras > 0 {
+				cell.Write(t)
+			}
+
+		case xml.EndElement:
+			switch {
+			case t.Name.Space == odsTable &&
+				(t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				inCell = false
+				v := value
+				if v == "" {
+					v = cell.String()
+				}
+				if v == "" && colRep > odsMaxRepeat {
+					colRep = odsMaxRepeat
+				}
+				for i := 0; i < colRep; i++ {
+					row = append(row, v)
+				}
+			case t.Name.Space == odsTable && t.Name.Local == "table-row":
+				if cur == nil {
+					continue
+				}
+				for len(row) > 0 && row[len(row)-1] == "" {
+					row = row[:len(row)-1]
+				}
+				if len(row) == 0 {
+					empty += rowRep
+					continue
+				}
+				for ; empty > 0 && empty <= odsMaxRepeat; empty-- {
+					cur.rows = append(cur.rows, nil)
+				}
+				empty = 0
+				for i := 0; i < rowRep && i < odsMaxRepeat; i++ {
+					cur.rows = append(cur.rows, row)
+				}
+			}
+		}
+	}
+
+	return sheets, nil
+}
+
+// csvSource is a single sheet of delimiter separated values in UTF-8
+// or Windows-1252, which is what spreadsheet applications export.
+type csvSource string
+
+func (path csvSource) Sheets() ([]sheet, error) {
+	b, err := ioutil.ReadFile(string(path))
+	if err != nil {
+		return nil, err
+	}
+
+	text, enc, err := decodeText(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	diags.encoding(string(path), enc)
+
+	c := csv.NewReader(strings.NewReader(text))
+	c.Comma = csvDelimiter(text)
+	c.FieldsPerRecord = -1
+	c.LazyQuotes = true
+
+	rows, err := c.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	// CSV files have a single, unnamed, sheet
+	return []sheet{{"", rows}}, nil
+}
+
+// decodeText returns b as a string and the name of the encoding it was
+// found to be in.  Spreadsheet applications export Windows-1252 unless
+// told to use UTF-8.
+func decodeText(b []byte) (string, string, error) {
+	name, bom, err := sniffEncoding(b, "windows-1252")
+	if err != nil {
+		return "", "", err
+	}
+	s, err := decodeString(encodings[name].NewDecoder(), name, string(b[bom:]))
+	return s, name, err
+}
+
+// csvDelimiter guesses the delimiter of text: the candidate that
+// appears the same, non zero, number of times in most of the first
+// lines.
+func csvDelimiter(text string) rune {
+	var lines []string
+	s := bufio.NewScanner(strings.NewReader(text))
+	for s.Scan() && len(lines) < 20 {
+		if strings.TrimSpace(s.Text()) != "" {
+			lines = append(lines, s.Text())
+		}
+	}
+
+	best, score := ',', 0
+	for _, d := range []rune{',', ';', '\t', '|'} {
+		counts := make(map[int]int)
+		for _, l := range lines {
+			if n := strings.Count(l, string(d)); n > 0 {
+				counts[n]++
+			}
+		}
+		for _, c := range counts {
+			if c > score {
+				best, score = d, c
+			}
+		}
+	}
+	return best
+}
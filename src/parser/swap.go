@@ -0,0 +1,247 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"model"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/coopernurse/gorp"
+)
+
+// The database is built in a separate file and only renamed into place
+// once it passes the checks, so that the server never sees a half
+// built database and a failed import leaves the current one alone.
+
+// startBuild returns the path of the file to build the database at
+// path in.  Unless fresh is set, it starts as a copy of the current
+// database, so that data not coming from the TSE files, like the
+// scraped addresses, is kept.
+func startBuild(path string, fresh bool) (string, error) {
+	tmp := path + ".new"
+
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if fresh {
+		return tmp, nil
+	}
+
+	if err := copyFile(path, tmp); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	return tmp, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// migrate brings the database being built at path to the current
+// schema, creating it if it's new.
+func migrate(path string) error {
+	db, err := model.Open(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	from, err := model.Migrate(db)
+	if err == nil && from < model.SchemaVersion {
+		log.Printf("I: %s: schema version %d to %d", path, from, model.SchemaVersion)
+	}
+	return err
+}
+
+// checkBuild makes sure the database is fit to be published: SQLite
+// finds no corruption, every padron entry has its persona and the
+// padron of the election didn't drop more than maxShrink (a fraction)
+// from before, its size in the previous database.
+func checkBuild(dbmap *gorp.DbMap, eleccionId, before int64, maxShrink float64) error {
+	ok, err := dbmap.SelectStr("PRAGMA integrity_check")
+	if err != nil {
+		return err
+	}
+	if ok != "ok" {
+		return fmt.Errorf("integrity check failed: %s", ok)
+	}
+
+	orphans, err := dbmap.SelectInt(`SELECT COUNT(*) FROM padron
+		LEFT JOIN personas ON personas.id = padron.persona_id
+		WHERE personas.id IS NULL`)
+	if err != nil {
+		return err
+	}
+	if orphans > 0 {
+		return fmt.Errorf("%d padron entries without persona", orphans)
+	}
+
+	after, err := dbmap.SelectInt("SELECT COUNT(*) FROM padron WHERE eleccion_id = ?",
+		eleccionId)
+	if err != nil {
+		return err
+	}
+	if float64(after) < float64(before)*(1-maxShrink) {
+		return fmt.Errorf("the padron went from %d to %d personas", before, after)
+	}
+
+	return nil
+}
+
+// countPadron returns the number of personas in the padron of the
+// election called eleccion, the latest if empty, in the database at
+// path.  Zero if there is none yet.
+func countPadron(path, eleccion string) (int64, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	// Its schema may be older, it's the copy that is migrated
+	db, err := model.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	dbmap := &gorp.DbMap{Db: db, Dialect: gorp.SqliteDialect{}}
+
+	e, err := model.FindEleccion(dbmap, eleccion)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return dbmap.SelectInt("SELECT COUNT(*) FROM padron WHERE eleccion_id = ?", e.Id)
+}
+
+// syncFile flushes the file, or directory, at path to the disk.
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// swapIn renames tmp to path, keeping the previous keep databases as
+// path.1 (the latest) to path.N.  tmp is flushed first, and the
+// directory after the rename, for a crash not to leave a broken or
+// missing database at path.
+func swapIn(tmp, path string, keep int) error {
+	if err := syncFile(tmp); err != nil {
+		return err
+	}
+
+	if keep > 0 {
+		for i := keep; i > 1; i-- {
+			err := os.Rename(path+"."+strconv.Itoa(i-1), path+"."+strconv.Itoa(i))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		prev := path + ".1"
+		if err := os.Remove(prev); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// A hard link keeps path in place until the rename below
+		if err := os.Link(path, prev); err != nil && !os.IsNotExist(err) {
+			if err := copyFile(path, prev); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncFile(filepath.Dir(path))
+}
+
+// hashFile returns the hex encoded SHA-256 of the file at path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// startImport adds this import to importaciones, so that a database
+// tells where its data came from, with its input files so that it can
+// be resumed.  finishImport completes it.
+func startImport(trans *gorp.Transaction, eleccionId int64) (*model.Importacion, error) {
+	diags.mu.Lock()
+	archivos, err := json.Marshal(diags.Inputs)
+	diags.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	imp := &model.Importacion{
+		EleccionId: eleccionId,
+		Fecha:      time.Now().Unix(),
+		Archivos:   string(archivos),
+	}
+	if err := trans.Insert(imp); err != nil {
+		return nil, err
+	}
+	return imp, nil
+}
+
+// finishImport records the files and outcome of the import imp.
+func finishImport(trans *gorp.Transaction, imp *model.Importacion) error {
+	personas, err := trans.SelectInt("SELECT COUNT(*) FROM padron WHERE eleccion_id = ?",
+		imp.EleccionId)
+	if err != nil {
+		return err
+	}
+
+	diags.mu.Lock()
+	archivos, err := json.Marshal(diags.Inputs)
+	imp.Errores, imp.Advertencias = int64(diags.Errors), int64(diags.Warnings)
+	diags.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	imp.Archivos = string(archivos)
+	imp.Personas = personas
+	imp.Terminada = true
+
+	_, err = trans.Update(imp)
+	return err
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/coopernurse/gorp"
+)
+
+// Validate checks the centros list against the juntas list and decides
+// which centro each junta belongs to:
+//
+//   - every junta in the range of a centro must be in the juntas list
+//     and belong to the distrito electoral of the centro,
+//   - ranges must not be reversed nor overlap one listed before, the
+//     centros with such ranges are dropped,
+//   - every junta in the juntas list must belong to a centro, those
+//     that don't can't be loaded.
+func (p *Padron) Validate() {
+	p.JuntaCentro = make(map[int]int)
+
+	ids := make([]int, 0, len(p.Centros))
+	for id := range p.Centros {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		centro := p.Centros[id]
+		at := centro.origin
+
+		if centro.JuntaEndId < centro.JuntaStartId {
+			diags.add(at.diag(sevError, ruleRangeOrder, fieldFinal, strconv.Itoa(centro.JuntaEndId),
+				fmt.Sprintf("range of centro %q ends before its start %d",
+					centro.Nombre, centro.JuntaStartId)))
+			delete(p.Centros, id)
+			continue
+		}
+
+		overlaps := false
+		for jid := centro.JuntaStartId; jid <= centro.JuntaEndId; jid++ {
+			if owner, ok := p.JuntaCentro[jid]; ok {
+				other := p.Centros[owner]
+				diags.add(at.diag(sevError, ruleRangeOverlap, fieldInicial, strconv.Itoa(jid),
+					fmt.Sprintf("junta already belongs to centro %q in %s row %d",
+						other.Nombre, other.origin.file, other.origin.row)))
+				overlaps = true
+			}
+		}
+		if overlaps {
+			delete(p.Centros, id)
+			continue
+		}
+
+		for jid := centro.JuntaStartId; jid <= centro.JuntaEndId; jid++ {
+			p.JuntaCentro[jid] = id
+
+			junta, ok := p.Juntas[jid]
+			if !ok {
+				diags.add(at.diag(sevWarning, ruleRangeMissing, fieldInicial, strconv.Itoa(jid),
+					fmt.Sprintf("junta of centro %q is not in the juntas list", centro.Nombre)))
+				continue
+			}
+
+			if de := p.DEs[junta.DEId]; !sameName(de, centro.DE) {
+				diags.add(at.diag(sevWarning, ruleDEMismatch, fieldDE, centro.DE,
+					fmt.Sprintf("junta %d is in distrito electoral %s %q", jid, junta.DEId, de)))
+			}
+		}
+	}
+
+	jids := make([]int, 0, len(p.Juntas))
+	for jid := range p.Juntas {
+		jids = append(jids, jid)
+	}
+	sort.Ints(jids)
+
+	for _, jid := range jids {
+		if _, ok := p.JuntaCentro[jid]; !ok {
+			junta := p.Juntas[jid]
+			diags.add(junta.origin.diag(sevError, ruleOrphanJunta, fieldJunta, strconv.Itoa(jid),
+				"junta is not in the range of any centro"))
+		}
+	}
+}
+
+// CheckElectores compares the number of electores of each junta in the
+// juntas list with the number of personas the padron assigns to it.
+func (p *Padron) CheckElectores(trans *gorp.Transaction, eleccionId int64) error {
+	type Count struct {
+		JuntaId   int64
+		Electores int64
+		Padron    int64
+	}
+
+	var counts []Count
+
+	_, err := trans.Select(
+		&counts,
+		`SELECT
+			juntas.id AS JuntaId,
+			juntas.electores AS Electores,
+			COUNT(padron.persona_id) AS Padron
+		FROM
+			juntas
+		LEFT JOIN
+			padron ON padron.eleccion_id = juntas.eleccion_id
+				AND padron.junta_id = juntas.id
+		WHERE juntas.eleccion_id = ?
+		GROUP BY juntas.id
+		HAVING Electores != Padron
+		ORDER BY juntas.id`, eleccionId)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range counts {
+		junta, ok := p.Juntas[int(c.JuntaId)]
+		if !ok {
+			// Not in the juntas list, already reported
+			continue
+		}
+		diags.add(junta.origin.diag(sevWarning, ruleElectores, fieldElectores,
+			strconv.FormatInt(c.Electores, 10),
+			fmt.Sprintf("the padron has %d electores in junta %d", c.Padron, c.JuntaId)))
+	}
+
+	var unknown []Count
+
+	_, err = trans.Select(
+		&unknown,
+		`SELECT
+			padron.junta_id AS JuntaId,
+			COUNT(*) AS Padron
+		FROM
+			padron
+		LEFT JOIN
+			juntas ON juntas.eleccion_id = padron.eleccion_id
+				AND juntas.id = padron.junta_id
+		WHERE padron.eleccion_id = ? AND juntas.id IS NULL
+		GROUP BY padron.junta_id
+		ORDER BY padron.junta_id`, eleccionId)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range unknown {
+		diags.add(diagnostic{
+			Severity: sevWarning,
+			Rule:     ruleUnknownJunta,
+			Column:   fieldJunta,
+			Value:    strconv.FormatInt(c.JuntaId, 10),
+			Message:  fmt.Sprintf("%d electores of the padron are in a junta of no centro", c.Padron),
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,501 @@
+// Package qr encodes short texts (URLs) as QR codes.
+//
+// Only what is needed for printing links is implemented: byte mode,
+// error correction level M and versions 1 to 10, which is enough for
+// up to 213 bytes of data.
+package qr
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// quietZone is the width in modules of the blank border required
+// around the symbol.
+const quietZone = 4
+
+var ErrTooLong = errors.New("qr: data too long")
+
+// Code is an encoded QR symbol.
+type Code struct {
+	Size    int
+	modules [][]bool
+}
+
+// Black reports whether the module at column x and row y is dark.
+func (c *Code) Black(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Image renders the code with scale pixels per module, including the
+// quiet zone.
+func (c *Code) Image(scale int) image.Image {
+	n := (c.Size + 2*quietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, n, n))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray((x+quietZone)*scale+dx,
+						(y+quietZone)*scale+dy, color.Gray{0})
+				}
+			}
+		}
+	}
+	return img
+}
+
+// versionInfo describes the block structure for error correction
+// level M of a given version.
+type versionInfo struct {
+	ecPerBlock int
+	blocks1    int // blocks in group 1
+	data1      int // data codewords per block in group 1
+	blocks2    int // blocks in group 2
+	data2      int // data codewords per block in group 2
+	alignment  []int
+}
+
+var versions = [...]versionInfo{
+	1:  {10, 1, 16, 0, 0, nil},
+	2:  {16, 1, 28, 0, 0, []int{6, 18}},
+	3:  {26, 1, 44, 0, 0, []int{6, 22}},
+	4:  {18, 2, 32, 0, 0, []int{6, 26}},
+	5:  {24, 2, 43, 0, 0, []int{6, 30}},
+	6:  {16, 4, 27, 0, 0, []int{6, 34}},
+	7:  {18, 4, 31, 0, 0, []int{6, 22, 38}},
+	8:  {22, 2, 38, 2, 39, []int{6, 24, 42}},
+	9:  {22, 3, 36, 2, 37, []int{6, 26, 46}},
+	10: {26, 4, 43, 1, 44, []int{6, 28, 50}},
+}
+
+func (v versionInfo) dataCodewords() int {
+	return v.blocks1*v.data1 + v.blocks2*v.data2
+}
+
+// Encode returns the smallest QR code holding data.
+func Encode(data []byte) (*Code, error) {
+	for ver := 1; ver < len(versions); ver++ {
+		countBits := 8
+		if ver >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*versions[ver].dataCodewords() {
+			return encode(ver, countBits, data), nil
+		}
+	}
+	return nil, ErrTooLong
+}
+
+func encode(ver, countBits int, data []byte) *Code {
+	v := versions[ver]
+
+	// Data codewords: byte mode indicator, character count, data,
+	// terminator and padding
+	var bb bitBuffer
+	bb.append(0x4, 4)
+	bb.append(len(data), countBits)
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	capacity := 8 * v.dataCodewords()
+	for i := 0; i < 4 && bb.len() < capacity; i++ {
+		bb.append(0, 1)
+	}
+	for bb.len()%8 != 0 {
+		bb.append(0, 1)
+	}
+	for pad := 0xec; bb.len() < capacity; pad ^= 0xec ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	// Split in blocks, compute error correction and interleave
+	var blocks, ecc [][]byte
+	codewords := bb.bytes()
+	gen := rsGenerator(v.ecPerBlock)
+	for i := 0; i < v.blocks1+v.blocks2; i++ {
+		n := v.data1
+		if i >= v.blocks1 {
+			n = v.data2
+		}
+		blocks = append(blocks, codewords[:n])
+		ecc = append(ecc, rsRemainder(codewords[:n], gen))
+		codewords = codewords[n:]
+	}
+
+	var final []byte
+	for i := 0; i < v.data2 || i < v.data1; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				final = append(final, b[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for _, e := range ecc {
+			final = append(final, e[i])
+		}
+	}
+
+	m := newMatrix(ver)
+	m.drawFunctionPatterns(v)
+	m.drawCodewords(final)
+
+	best, bestPenalty := -1, 0
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormatBits(mask)
+		if p := m.penalty(); best < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		m.applyMask(mask) // undo
+	}
+	m.applyMask(best)
+	m.drawFormatBits(best)
+
+	return &Code{Size: m.size, modules: m.modules}
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, (v>>uint(i))&1 != 0)
+	}
+}
+
+func (bb bitBuffer) len() int { return len(bb) }
+
+func (bb bitBuffer) bytes() []byte {
+	b := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			b[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return b
+}
+
+// Reed-Solomon over GF(2^8) with the polynomial x^8+x^4+x^3+x^2+1.
+
+func gfMul(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		hi := z & 0x80
+		z <<= 1
+		if hi != 0 {
+			z ^= 0x1d
+		}
+		if (y>>uint(i))&1 != 0 {
+			z ^= x
+		}
+	}
+	return z
+}
+
+// rsGenerator returns the coefficients of the generator polynomial of
+// the given degree, highest power first and leading 1 omitted.
+func rsGenerator(degree int) []byte {
+	g := make([]byte, degree)
+	g[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range g {
+			g[j] = gfMul(g[j], root)
+			if j+1 < len(g) {
+				g[j] ^= g[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return g
+}
+
+func rsRemainder(data, gen []byte) []byte {
+	r := make([]byte, len(gen))
+	for _, b := range data {
+		factor := b ^ r[0]
+		copy(r, r[1:])
+		r[len(r)-1] = 0
+		for i := range r {
+			r[i] ^= gfMul(gen[i], factor)
+		}
+	}
+	return r
+}
+
+type matrix struct {
+	size     int
+	version  int
+	modules  [][]bool
+	function [][]bool
+}
+
+func newMatrix(ver int) *matrix {
+	size := 17 + 4*ver
+	m := &matrix{size: size, version: ver}
+	m.modules = make([][]bool, size)
+	m.function = make([][]bool, size)
+	for i := range m.modules {
+		m.modules[i] = make([]bool, size)
+		m.function[i] = make([]bool, size)
+	}
+	return m
+}
+
+func (m *matrix) set(x, y int, dark bool) {
+	m.modules[y][x] = dark
+	m.function[y][x] = true
+}
+
+func (m *matrix) drawFunctionPatterns(v versionInfo) {
+	for i := 0; i < m.size; i++ {
+		m.set(6, i, i%2 == 0)
+		m.set(i, 6, i%2 == 0)
+	}
+
+	m.drawFinder(3, 3)
+	m.drawFinder(m.size-4, 3)
+	m.drawFinder(3, m.size-4)
+
+	n := len(v.alignment)
+	for i, x := range v.alignment {
+		for j, y := range v.alignment {
+			// Skip the corners taken by the finders
+			if i == 0 && j == 0 || i == 0 && j == n-1 || i == n-1 && j == 0 {
+				continue
+			}
+			m.drawAlignment(x, y)
+		}
+	}
+
+	// Reserve the format areas, they are filled after masking
+	m.drawFormatBits(0)
+	m.drawVersion()
+}
+
+func (m *matrix) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= m.size || y < 0 || y >= m.size {
+				continue
+			}
+			d := ring(dx, dy)
+			m.set(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+func (m *matrix) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			m.set(cx+dx, cy+dy, ring(dx, dy) != 1)
+		}
+	}
+}
+
+func (m *matrix) drawFormatBits(mask int) {
+	// Level M is 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>uint(i))&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		m.set(8, i, bit(i))
+	}
+	m.set(8, 7, bit(6))
+	m.set(8, 8, bit(7))
+	m.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		m.set(m.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.set(8, m.size-15+i, bit(i))
+	}
+	m.set(8, m.size-8, true)
+}
+
+func (m *matrix) drawVersion() {
+	if m.version < 7 {
+		return
+	}
+	rem := m.version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	bits := m.version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 != 0
+		a, b := m.size-11+i%3, i/3
+		m.set(a, b, dark)
+		m.set(b, a, dark)
+	}
+}
+
+func (m *matrix) drawCodewords(data []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < m.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = m.size - 1 - vert
+				}
+				if m.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				m.modules[y][x] = (data[i>>3]>>uint(7-(i&7)))&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func (m *matrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !m.function[y][x] {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the matrix following the rules in ISO/IEC 18004
+// section 7.8.3, lower is better.
+func (m *matrix) penalty() int {
+	p := 0
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return m.modules[x][y]
+		}
+		return m.modules[y][x]
+	}
+
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < m.size; y++ {
+			// Runs of five or more modules of the same color
+			run := 1
+			for x := 1; x < m.size; x++ {
+				if at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += 3 + run - 5
+				}
+				run = 1
+			}
+			if run >= 5 {
+				p += 3 + run - 5
+			}
+
+			// Finder-like 1:1:3:1:1 patterns with four light
+			// modules on either side
+			for x := 0; x+7 <= m.size; x++ {
+				if !at(x, y, vertical) || at(x+1, y, vertical) ||
+					!at(x+2, y, vertical) || !at(x+3, y, vertical) ||
+					!at(x+4, y, vertical) || at(x+5, y, vertical) ||
+					!at(x+6, y, vertical) {
+					continue
+				}
+				if m.light(x-4, x, y, vertical) || m.light(x+7, x+11, y, vertical) {
+					p += 40
+				}
+			}
+		}
+	}
+
+	// 2x2 blocks of the same color
+	dark := 0
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if m.modules[y][x] {
+				dark++
+			}
+			if x+1 < m.size && y+1 < m.size {
+				c := m.modules[y][x]
+				if c == m.modules[y][x+1] && c == m.modules[y+1][x] &&
+					c == m.modules[y+1][x+1] {
+					p += 3
+				}
+			}
+		}
+	}
+
+	// Proportion of dark modules away from 50%
+	total := m.size * m.size
+	k := abs(dark*20-total*10) / total
+	p += k * 10
+
+	return p
+}
+
+// light reports whether modules from..to-1 in the line are light,
+// treating modules outside the symbol as light.
+func (m *matrix) light(from, to, line int, vertical bool) bool {
+	for i := from; i < to; i++ {
+		if i < 0 || i >= m.size {
+			continue
+		}
+		if vertical && m.modules[i][line] || !vertical && m.modules[line][i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ring returns which concentric square around the origin (dx, dy)
+// belongs to.
+func ring(dx, dy int) int {
+	if abs(dy) > abs(dx) {
+		return abs(dy)
+	}
+	return abs(dx)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	digitsRe    = regexp.MustCompile(`^\d+$`)
+	separatorRe = regexp.MustCompile(`[ -]+`)
+	cedulaRe    = regexp.MustCompile(`^\d{9}$`)
+)
+
+// NormalizeCedula converts the usual ways of writing a cedula number
+// to the nine digit form used in the database, the same way the web
+// page does:
+//
+//	1234567     => 102340567
+//	12345678    => 102345678
+//	0123456789  => 123456789
+//	1-234-567   => 102340567
+//	1 2345 6789 => 123456789
+func NormalizeCedula(s string) string {
+	cedula := strings.TrimSpace(s)
+
+	if digitsRe.MatchString(cedula) {
+		switch len(cedula) {
+		case 7:
+			// PMMMNNN => P0MMM0NNN
+			cedula = cedula[0:1] + "0" + cedula[1:4] + "0" + cedula[4:7]
+		case 8:
+			// PMMMNNNN => P0MMMNNNN
+			cedula = cedula[0:1] + "0" + cedula[1:4] + cedula[4:8]
+		case 10:
+			// 0PMMMMNNNN => PMMMMNNNN
+			cedula = cedula[1:]
+		}
+		return cedula
+	}
+
+	items := separatorRe.Split(cedula, -1)
+	switch len(items) {
+	case 2, 3:
+		items[0] = strings.TrimLeft(items[0], "0")
+		for i := 1; i < len(items); i++ {
+			for len(items[i]) < 4 {
+				items[i] = "0" + items[i]
+			}
+		}
+		cedula = strings.Join(items, "")
+	}
+
+	return cedula
+}
+
+// validCedula reports whether s is a normalized cedula number.
+func validCedula(s string) bool {
+	return cedulaRe.MatchString(s)
+}
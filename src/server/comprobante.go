@@ -0,0 +1,44 @@
+package server
+
+import (
+	"comprobante"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+)
+
+// GetComprobante renders a printable slip with the persona's voting
+// site and a QR code linking back to the consultation page.
+func GetComprobante(w http.ResponseWriter, r *http.Request) error {
+	id, err := parseID(r)
+	if err != nil {
+		return badRequest{err}
+	}
+
+	persona, err := lookupPersona(id, r.URL.Query().Get("eleccion"))
+	if err != nil {
+		return err
+	}
+
+	slip := &comprobante.Slip{
+		Nombre:    persona.Nombre + " " + persona.Apellido1 + " " + persona.Apellido2,
+		Cedula:    persona.Cedula,
+		Junta:     persona.Mesa,
+		Centro:    persona.Centro,
+		Direccion: persona.Direccion,
+		Distrito:  persona.Distrito,
+		Canton:    persona.Canton,
+		Provincia: persona.Provincia,
+		URL:       baseURL(r) + "/?" + url.Values{"cedula": {persona.Cedula}}.Encode(),
+	}
+
+	switch mux.Vars(r)["formato"] {
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		return comprobante.WritePDF(w, slip)
+	default:
+		w.Header().Set("Content-Type", "image/png")
+		return comprobante.WritePNG(w, slip)
+	}
+}
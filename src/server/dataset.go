@@ -0,0 +1,269 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"model"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/coopernurse/gorp"
+	"github.com/mattn/go-sqlite3"
+)
+
+// dataset is an open database.  Handlers hold a reference while they
+// use it, so that a reload only closes it once they are done.
+type dataset struct {
+	dbmap      *gorp.DbMap
+	info       os.FileInfo
+	personas   int64
+	provenance string
+	refs       sync.WaitGroup
+}
+
+var (
+	dbPath  string
+	dbMu    sync.RWMutex
+	current *dataset
+	// Serializes reloads
+	reloadMu sync.Mutex
+)
+
+// acquireDb returns the current database and the function to call
+// when done with it.
+func acquireDb() (*gorp.DbMap, func()) {
+	dbMu.RLock()
+	ds := current
+	ds.refs.Add(1)
+	dbMu.RUnlock()
+	return ds.dbmap, ds.refs.Done
+}
+
+// Connections kept open by a dataset when idle
+const idleConns = 8
+
+var errReplaced = errors.New("the database was replaced, waiting for the reload")
+
+// fileConnector opens connections to the database at path as long as
+// it is still the file described by info.  database/sql opens them by
+// path as needed, and one opened after the file was replaced would
+// see the new database among those of the old one.  A file is
+// replaced by renaming another over it, so if path is still info once
+// the connection is open, it was when it was opened.
+type fileConnector struct {
+	path string
+	info os.FileInfo
+}
+
+func (c fileConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(c.path)
+	if err == nil && !os.SameFile(info, c.info) {
+		err = errReplaced
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c fileConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// openDataset opens the database at path and checks it can be served.
+func openDataset(path string) (*dataset, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(fileConnector{path, info})
+	db.SetMaxIdleConns(idleConns)
+	dbmap, err := model.NewDbMap(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	ds := &dataset{dbmap: dbmap, info: info}
+
+	ok, err := dbmap.SelectStr("PRAGMA quick_check")
+	if err == nil && ok != "ok" {
+		err = fmt.Errorf("integrity check failed: %s", ok)
+	}
+	if err == nil {
+		ds.personas, err = dbmap.SelectInt("SELECT COUNT(*) FROM personas")
+	}
+	if err == nil {
+		ds.provenance, err = provenance(dbmap)
+	}
+	if err != nil {
+		dbmap.Db.Close()
+		return nil, err
+	}
+
+	return ds, nil
+}
+
+// provenance describes the last import recorded in the database.
+func provenance(dbmap *gorp.DbMap) (string, error) {
+	var imports []model.Importacion
+
+	_, err := dbmap.Select(&imports,
+		`SELECT * FROM importaciones ORDER BY id DESC LIMIT 1`)
+	if err != nil {
+		return "", err
+	}
+	if len(imports) == 0 {
+		return "no import recorded", nil
+	}
+	imp := imports[0]
+
+	var archivos []struct {
+		File   string `json:"file"`
+		Sha256 string `json:"sha256"`
+	}
+	if err := json.Unmarshal([]byte(imp.Archivos), &archivos); err != nil {
+		return "", err
+	}
+	files := make([]string, len(archivos))
+	for i, a := range archivos {
+		sum := a.Sha256
+		if len(sum) > 12 {
+			sum = sum[:12]
+		}
+		files[i] = a.File + " " + sum
+	}
+
+	return fmt.Sprintf("import %d of %s, %d personas (%s)",
+		imp.Id, time.Unix(imp.Fecha, 0).Format("2006-01-02 15:04:05"),
+		imp.Personas, strings.Join(files, ", ")), nil
+}
+
+// openDatabase opens the database served by the handlers.
+func openDatabase(path string) error {
+	ds, err := openDataset(path)
+	if err != nil {
+		return err
+	}
+	dbPath = path
+	current = ds
+	log.Printf("I: Serving %s: %s", path, ds.provenance)
+	return nil
+}
+
+// Reload opens the database again, in case it was replaced, and
+// switches the handlers to it.  Requests using the previous one finish
+// with it.  On error the previous one is kept.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	ds, err := openDataset(dbPath)
+	if err != nil {
+		return err
+	}
+
+	dbMu.RLock()
+	old := current
+	dbMu.RUnlock()
+
+	if ds.personas == 0 && old.personas > 0 {
+		ds.dbmap.Db.Close()
+		return errors.New("the new database has no personas")
+	}
+
+	dbMu.Lock()
+	current = ds
+	dbMu.Unlock()
+
+	log.Printf("I: Reloaded %s", dbPath)
+	log.Printf("I: Was: %s", old.provenance)
+	log.Printf("I: Now: %s", ds.provenance)
+
+	go func() {
+		old.refs.Wait()
+		old.dbmap.Db.Close()
+	}()
+
+	return nil
+}
+
+// reloadOnChange reloads the database when the file at its path is
+// replaced or modified, checking every interval, or on SIGHUP.
+func reloadOnChange(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		tick = time.NewTicker(interval).C
+	}
+
+	dbMu.RLock()
+	seen := current.info
+	dbMu.RUnlock()
+
+	for {
+		select {
+		case <-hup:
+			log.Printf("I: SIGHUP, reloading %s", dbPath)
+		case <-tick:
+			info, err := os.Stat(dbPath)
+			if err != nil || (os.SameFile(info, seen) &&
+				info.ModTime().Equal(seen.ModTime()) && info.Size() == seen.Size()) {
+				continue
+			}
+			// Don't retry a broken file until it changes again
+			seen = info
+			log.Printf("I: %s changed, reloading", dbPath)
+		}
+
+		if err := Reload(); err != nil {
+			log.Printf("W: Can't reload %s, still serving the previous one: %s", dbPath, err)
+			continue
+		}
+		dbMu.RLock()
+		seen = current.info
+		dbMu.RUnlock()
+	}
+}
+
+// reloadHandler reloads the database on a POST carrying the admin
+// token as "Authorization: Bearer <token>".
+func reloadHandler(token string) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte(token)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return nil
+		}
+
+		if err := Reload(); err != nil {
+			log.Printf("W: Can't reload %s: %s", dbPath, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return nil
+		}
+
+		dbMu.RLock()
+		p := current.provenance
+		dbMu.RUnlock()
+		fmt.Fprintln(w, p)
+		return nil
+	}
+}
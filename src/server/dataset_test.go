@@ -0,0 +1,39 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplacedDatabase(t *testing.T) {
+	tmp := openTestDatabase(t)
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "padron.db")
+
+	b, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = ioutil.WriteFile(path+".new", b, 0644)
+	}
+	if err == nil {
+		err = os.Rename(path+".new", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A new connection to the previous dataset would open the new file
+	old := current
+	old.dbmap.Db.SetMaxIdleConns(0)
+	if _, err := old.dbmap.SelectInt("SELECT COUNT(*) FROM personas"); err != errReplaced {
+		t.Errorf("query after the file was replaced: %v, want %v", err, errReplaced)
+	}
+
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := current.dbmap.SelectInt("SELECT COUNT(*) FROM personas"); err != nil || n != 1 {
+		t.Errorf("query after the reload: %d, %v", n, err)
+	}
+}
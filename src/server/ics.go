@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"model"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// costaRica is the time zone polling hours are given in.  It has had
+// no daylight saving time since 1992.
+var costaRica = time.FixedZone("CST", -6*60*60)
+
+// election holds the election day and polling hours.
+type election struct {
+	open, close time.Time
+}
+
+// pollingHours holds the configured polling hours (HH:MM, Costa Rica
+// time) and the election day (YYYY-MM-DD) of elections without one.
+type pollingHours struct {
+	open, close, date string
+}
+
+// election returns the election held on date, or on the configured
+// day if empty.
+func (h pollingHours) election(date string) (*election, error) {
+	if date == "" {
+		date = h.date
+	}
+	if date == "" {
+		return nil, notFound{errors.New("fecha de la elección desconocida")}
+	}
+	return parseElection(date, h.open, h.close)
+}
+
+func parseElection(date, open, close string) (*election, error) {
+	o, err := time.ParseInLocation("2006-01-02 15:04", date+" "+open, costaRica)
+	if err != nil {
+		return nil, err
+	}
+	c, err := time.ParseInLocation("2006-01-02 15:04", date+" "+close, costaRica)
+	if err != nil {
+		return nil, err
+	}
+	if !c.After(o) {
+		return nil, fmt.Errorf("polls close (%s) before they open (%s)", close, open)
+	}
+	return &election{open: o, close: c}, nil
+}
+
+// icsText escapes s as an iCalendar TEXT value (RFC 5545, 3.3.11).
+var icsText = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// writeICSLine writes a content line folded at 75 octets, without
+// splitting UTF-8 sequences.
+func writeICSLine(b *bytes.Buffer, name, value string) {
+	line := name + ":" + value
+	limit := 75
+	for len(line) > limit {
+		n := limit
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		b.WriteString(line[:n] + "\r\n ")
+		line = line[n:]
+		limit = 74 // the leading space counts
+	}
+	b.WriteString(line + "\r\n")
+}
+
+// GetEleccion returns an iCalendar event for the day of the election
+// given by the eleccion query parameter, the latest one if missing, at
+// the persona's centro.
+func GetEleccion(h pollingHours) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		id, err := parseID(r)
+		if err != nil {
+			return badRequest{err}
+		}
+
+		dbmap, release := acquireDb()
+		persona, err := FindPersona(dbmap, id, r.URL.Query().Get("eleccion"))
+		var eleccion *model.Eleccion
+		if err == nil {
+			eleccion, err = findEleccion(dbmap, persona.Eleccion)
+		}
+		release()
+		if err != nil {
+			return err
+		}
+
+		e, err := h.election(eleccion.Fecha)
+		if err != nil {
+			return err
+		}
+
+		const stamp = "20060102T150405Z"
+
+		location := strings.Join([]string{persona.Centro, persona.Direccion,
+			persona.Distrito, persona.Canton, persona.Provincia}, ", ")
+		description := fmt.Sprintf("Junta receptora de votos %s.\n"+
+			"Centro de votación: %s.\n"+
+			"Recuerde llevar su cédula de identidad.",
+			persona.Mesa, persona.Centro)
+
+		var b bytes.Buffer
+		writeICSLine(&b, "BEGIN", "VCALENDAR")
+		writeICSLine(&b, "VERSION", "2.0")
+		writeICSLine(&b, "PRODID", "-//VotoCR//padron//ES")
+		writeICSLine(&b, "METHOD", "PUBLISH")
+		writeICSLine(&b, "BEGIN", "VEVENT")
+		writeICSLine(&b, "UID", fmt.Sprintf("%s-%s@%s", persona.Cedula,
+			e.open.Format("20060102"), r.Host))
+		writeICSLine(&b, "DTSTAMP", time.Now().UTC().Format(stamp))
+		writeICSLine(&b, "DTSTART", e.open.UTC().Format(stamp))
+		writeICSLine(&b, "DTEND", e.close.UTC().Format(stamp))
+		writeICSLine(&b, "SUMMARY", icsText.Replace("Ir a votar"))
+		writeICSLine(&b, "LOCATION", icsText.Replace(location))
+		writeICSLine(&b, "DESCRIPTION", icsText.Replace(description))
+		writeICSLine(&b, "URL", baseURL(r)+"/?"+
+			url.Values{"cedula": {persona.Cedula}}.Encode())
+		writeICSLine(&b, "END", "VEVENT")
+		writeICSLine(&b, "END", "VCALENDAR")
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="eleccion.ics"`)
+		_, err = b.WriteTo(w)
+		return err
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGetEleccion(t *testing.T) {
+	tmp := openTestDatabase(t)
+	defer os.RemoveAll(tmp)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/persona/{id}/eleccion.ics",
+		errorHandler(GetEleccion(pollingHours{"06:00", "18:00", "2022-02-06"})))
+
+	tests := []struct {
+		url    string
+		status int
+		start  string // contained in the event if OK
+	}{
+		// On the date of the election, not the configured one
+		{"/persona/101110111/eleccion.ics", http.StatusOK, "DTSTART:20180204T120000Z"},
+		{"/persona/101110111/eleccion.ics?eleccion=2018", http.StatusOK, "DTSTART:20180204T120000Z"},
+		{"/persona/101110111/eleccion.ics?eleccion=2014", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.url, w.Code, tt.status)
+			continue
+		}
+		if tt.start != "" && !strings.Contains(w.Body.String(), tt.start+"\r\n") {
+			t.Errorf("%s: got\n%s\nwant %s", tt.url, w.Body, tt.start)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Junta is a junta receptora as returned by the API.  Electores is the
+// number published in the TSE juntas list, Padron the number of
+// personas the padron assigns to it; they should match.
+type Junta struct {
+	Id         int64
+	Centro     string
+	Electores  int64
+	Padron     int64
+	Diferencia int64
+}
+
+func GetJunta(w http.ResponseWriter, r *http.Request) error {
+	txt, err := parseID(r)
+	if err != nil {
+		return badRequest{err}
+	}
+	id, err := strconv.ParseInt(txt, 10, 64)
+	if err != nil {
+		return badRequest{fmt.Errorf("junta inválida: %s", txt)}
+	}
+
+	dbmap, release := acquireDb()
+	defer release()
+
+	e, err := findEleccion(dbmap, r.URL.Query().Get("eleccion"))
+	if err != nil {
+		return err
+	}
+
+	var junta Junta
+
+	err = dbmap.SelectOne(&junta,
+		`SELECT
+			juntas.id AS Id,
+			centros.nombre AS Centro,
+			juntas.electores AS Electores,
+			(SELECT COUNT(*) FROM padron
+				WHERE eleccion_id = juntas.eleccion_id AND junta_id = juntas.id) AS Padron
+		FROM
+			juntas
+		JOIN
+			centros ON centros.eleccion_id = juntas.eleccion_id AND centros.id = juntas.centro_id
+		WHERE juntas.eleccion_id = ? AND juntas.id = ?`,
+		e.Id, id)
+	if err != nil {
+		return notFound{fmt.Errorf("junta no encontrada: %s", err)}
+	}
+	junta.Diferencia = junta.Padron - junta.Electores
+
+	return json.NewEncoder(w).Encode(junta)
+}
+
+// GetDiscrepancias lists the juntas whose number of electores in the
+// padron is not the published one.
+func GetDiscrepancias(w http.ResponseWriter, r *http.Request) error {
+	dbmap, release := acquireDb()
+	defer release()
+
+	e, err := findEleccion(dbmap, r.URL.Query().Get("eleccion"))
+	if err != nil {
+		return err
+	}
+
+	juntas := []Junta{}
+
+	_, err = dbmap.Select(&juntas,
+		`SELECT
+			juntas.id AS Id,
+			centros.nombre AS Centro,
+			juntas.electores AS Electores,
+			COUNT(padron.persona_id) AS Padron,
+			COUNT(padron.persona_id) - juntas.electores AS Diferencia
+		FROM
+			juntas
+		JOIN
+			centros ON centros.eleccion_id = juntas.eleccion_id AND centros.id = juntas.centro_id
+		LEFT JOIN
+			padron ON padron.eleccion_id = juntas.eleccion_id AND padron.junta_id = juntas.id
+		WHERE juntas.eleccion_id = ?
+		GROUP BY juntas.id
+		HAVING Diferencia != 0
+		ORDER BY juntas.id`, e.Id)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(juntas)
+}
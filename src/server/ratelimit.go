@@ -0,0 +1,126 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter allows up to limit requests per key (a client address, a
+// chat) in each window.  A zero limit disables it.
+type rateLimiter struct {
+	sync.Mutex
+	limit   int
+	window  time.Duration
+	start   time.Time
+	counter map[string]int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		window:  window,
+		start:   time.Now(),
+		counter: make(map[string]int),
+	}
+}
+
+// Allow records a request for key and reports whether it is within
+// the limit.
+func (l *rateLimiter) Allow(key string) bool {
+	if l == nil || l.limit <= 0 {
+		return true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if now := time.Now(); now.Sub(l.start) >= l.window {
+		l.start = now
+		l.counter = make(map[string]int)
+	}
+
+	l.counter[key]++
+
+	return l.counter[key] <= l.limit
+}
+
+// trustedProxies are the networks of the reverse proxies whose
+// X-Forwarded-For header is believed.
+var trustedProxies []*net.IPNet
+
+// parseProxies parses a comma separated list of addresses and CIDR
+// networks.
+func parseProxies(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// trusted reports whether addr is a trusted proxy.
+func trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientKey identifies the client that sent r for rate limiting
+// purposes: its address or, when it comes through trusted proxies,
+// the last address in X-Forwarded-For that isn't one of them.
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trusted(host) {
+		return host
+	}
+
+	var hops []string
+	for _, h := range r.Header["X-Forwarded-For"] {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		host = hop
+		if !trusted(hop) {
+			break
+		}
+	}
+	return host
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientKey(t *testing.T) {
+	proxies, err := parseProxies("10.0.0.1, 192.168.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedProxies = proxies
+	defer func() { trustedProxies = nil }()
+
+	tests := []struct {
+		remote, forwarded, key string
+	}{
+		{"203.0.113.5:1234", "", "203.0.113.5"},
+		// Anybody can send the header, only proxies are believed
+		{"203.0.113.5:1234", "198.51.100.7", "203.0.113.5"},
+		{"10.0.0.1:1234", "198.51.100.7", "198.51.100.7"},
+		// The client can prepend whatever it wants
+		{"10.0.0.1:1234", "1.2.3.4, 198.51.100.7", "198.51.100.7"},
+		{"10.0.0.1:1234", "1.2.3.4, 198.51.100.7, 192.168.1.1", "198.51.100.7"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+		{"10.0.0.1:1234", "basura", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/persona/101110111", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if key := clientKey(r); key != tt.key {
+			t.Errorf("%s forwarding %q: key %q, want %q", tt.remote, tt.forwarded, key, tt.key)
+		}
+	}
+}
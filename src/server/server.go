@@ -1,25 +1,139 @@
 package server
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"model"
 	"net/http"
+	"time"
 
+	"github.com/coopernurse/gorp"
 	"github.com/gorilla/mux"
 )
 
-func RegisterHandlers() {
+// Config holds the server settings.
+type Config struct {
+	// RateLimit is the number of lookups a single client can make
+	// per minute, zero means unlimited.
+	RateLimit int
+
+	// TrustedProxies is a comma separated list of the addresses or
+	// networks of the reverse proxies in front of the server, the
+	// client of their requests is taken from X-Forwarded-For.
+	TrustedProxies string
+
+	// Telegram bot settings, the webhook is only registered if
+	// TelegramToken is set.
+	TelegramToken  string
+	TelegramAPI    string
+	TelegramSecret string
+
+	// Polling hours (HH:MM, Costa Rica time, 06:00 to 18:00 if
+	// empty) for the calendar event, on the date of the election,
+	// or ElectionDate (YYYY-MM-DD) if it has none.
+	ElectionDate string
+	PollsOpen    string
+	PollsClose   string
+
+	// SMSAdapter names the adapter for the SMS gateway, the
+	// webhook is only registered if it is set.  SMSSecret, which
+	// it requires, authenticates the gateway.
+	SMSAdapter string
+	SMSSecret  string
+
+	// Database is the path of the SQLite database, padron.db if
+	// empty.  It is reloaded when the file changes (checked every
+	// Watch, zero disables it), on SIGHUP and on a POST to
+	// /admin/reload, only registered if AdminToken is set.
+	Database   string
+	Watch      time.Duration
+	AdminToken string
+}
+
+var limiter *rateLimiter
+
+func RegisterHandlers(cfg Config) {
+	limiter = newRateLimiter(cfg.RateLimit, time.Minute)
+	proxies, err := parseProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("E: Invalid trusted proxies: %s", err)
+	}
+	trustedProxies = proxies
+
+	if cfg.Database == "" {
+		cfg.Database = "padron.db"
+	}
+	if err := openDatabase(cfg.Database); err != nil {
+		log.Fatalf("E: Can't open %s: %s", cfg.Database, err)
+	}
+	go reloadOnChange(cfg.Watch)
+
 	r := mux.NewRouter()
-	r.HandleFunc("/persona/{id}", errorHandler(GetPersona)).Methods("GET")
+	r.HandleFunc("/persona/{id}", errorHandler(rateLimited(GetPersona))).Methods("GET")
+	r.HandleFunc("/persona/{id}/comprobante.{formato:pdf|png}",
+		errorHandler(rateLimited(GetComprobante))).Methods("GET")
+	hours := pollingHours{cfg.PollsOpen, cfg.PollsClose, cfg.ElectionDate}
+	if hours.open == "" {
+		hours.open = "06:00"
+	}
+	if hours.close == "" {
+		hours.close = "18:00"
+	}
+	date := hours.date
+	if date == "" {
+		// Any day, to check the hours
+		date = "2000-01-01"
+	}
+	if _, err := parseElection(date, hours.open, hours.close); err != nil {
+		log.Fatalf("E: Invalid election date or polling hours: %s", err)
+	}
+	r.HandleFunc("/persona/{id}/eleccion.ics",
+		errorHandler(rateLimited(GetEleccion(hours)))).Methods("GET")
+	r.HandleFunc("/junta/{id}", errorHandler(rateLimited(GetJunta))).Methods("GET")
+	r.HandleFunc("/juntas/discrepancias",
+		errorHandler(rateLimited(GetDiscrepancias))).Methods("GET")
+	r.HandleFunc("/widget", errorHandler(rateLimited(GetWidget))).Methods("GET")
+	r.HandleFunc("/oembed", errorHandler(GetOEmbed)).Methods("GET")
 	http.Handle("/persona/", r)
+	http.Handle("/junta/", r)
+	http.Handle("/juntas/", r)
+	http.Handle("/widget", r)
+	http.Handle("/oembed", r)
+
+	if cfg.TelegramToken != "" {
+		bot := NewTelegramBot(cfg.TelegramToken, cfg.TelegramAPI)
+		bot.Secret = cfg.TelegramSecret
+		r.Handle("/telegram", bot).Methods("POST")
+		http.Handle("/telegram", r)
+	}
+
+	if cfg.AdminToken != "" {
+		r.HandleFunc("/admin/reload", errorHandler(reloadHandler(cfg.AdminToken))).Methods("POST")
+		http.Handle("/admin/", r)
+	}
+
+	if cfg.SMSAdapter != "" {
+		a, ok := smsAdapters[cfg.SMSAdapter]
+		if !ok {
+			log.Fatalf("E: Unknown SMS adapter %q", cfg.SMSAdapter)
+		}
+		if cfg.SMSSecret == "" {
+			log.Fatalf("E: The SMS adapter needs a secret shared with the gateway")
+		}
+		r.HandleFunc("/sms", errorHandler(rateLimited(smsHandler(a, cfg.SMSSecret)))).Methods("POST")
+		http.Handle("/sms", r)
+	}
 }
 
 type badRequest struct{ error }
 
 type notFound struct{ error }
 
+type tooManyRequests struct{ error }
+
 func errorHandler(f func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := f(w, r)
@@ -30,8 +144,9 @@ func errorHandler(f func(w http.ResponseWriter, r *http.Request) error) http.Han
 		case badRequest:
 			http.Error(w, err.Error(), http.StatusBadRequest)
 		case notFound:
-			http.Error(w, "persona no encontrada: "+err.Error(),
-				http.StatusNotFound)
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case tooManyRequests:
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
 		default:
 			log.Println(err)
 			http.Error(w, "oops", http.StatusInternalServerError)
@@ -39,6 +154,15 @@ func errorHandler(f func(w http.ResponseWriter, r *http.Request) error) http.Han
 	}
 }
 
+func rateLimited(f func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if !limiter.Allow(clientKey(r)) {
+			return tooManyRequests{errors.New("demasiadas consultas, intente más tarde")}
+		}
+		return f(w, r)
+	}
+}
+
 func parseID(r *http.Request) (string, error) {
 	txt, ok := mux.Vars(r)["id"]
 	if !ok {
@@ -47,33 +171,61 @@ func parseID(r *http.Request) (string, error) {
 	return txt, nil
 }
 
-func GetPersona(w http.ResponseWriter, r *http.Request) error {
-	id, err := parseID(r)
-	log.Println("Id para persona ", id)
-	if err != nil {
-		return badRequest{err}
+// Persona is the information about a voter's assigned voting site
+// returned by the API.
+type Persona struct {
+	Cedula    string
+	Nombre    string
+	Apellido1 string
+	Apellido2 string
+	Centro    string
+	Direccion string
+	Url       string
+	Provincia string
+	Canton    string
+	Distrito  string
+	Mesa      string
+	Eleccion  string
+}
+
+// findEleccion returns the election called nombre, the latest one if
+// empty.
+func findEleccion(dbmap *gorp.DbMap, nombre string) (*model.Eleccion, error) {
+	e, err := model.FindEleccion(dbmap, nombre)
+	switch {
+	case err == sql.ErrNoRows && nombre == "":
+		return nil, notFound{errors.New("no hay elecciones")}
+	case err == sql.ErrNoRows:
+		return nil, notFound{fmt.Errorf("elección desconocida: %s", nombre)}
+	case err != nil:
+		return nil, err
 	}
+	return e, nil
+}
 
-	dbmap, err := model.InitDb()
-	if err != nil {
-		return fmt.Errorf(`E: Can't initialize database: %s. Abort.`, err)
+// lookupPersona finds the voting site for the persona with the given
+// cedula in the election called eleccion, the latest one if empty.
+func lookupPersona(cedula, eleccion string) (*Persona, error) {
+	dbmap, release := acquireDb()
+	defer release()
+
+	return FindPersona(dbmap, cedula, eleccion)
+}
+
+// FindPersona is lookupPersona in the database dbmap.
+func FindPersona(dbmap *gorp.DbMap, cedula, eleccion string) (*Persona, error) {
+	cedula = NormalizeCedula(cedula)
+	if !validCedula(cedula) {
+		return nil, badRequest{fmt.Errorf("cédula inválida: %s", cedula)}
 	}
-	defer dbmap.Db.Close()
 
-	var persona struct {
-		Cedula    string
-		Nombre    string
-		Apellido1 string
-		Apellido2 string
-		Centro    string
-		Direccion string
-		Url       string
-		Provincia string
-		Canton    string
-		Distrito  string
-		Mesa      string
+	e, err := findEleccion(dbmap, eleccion)
+	if err != nil {
+		return nil, err
 	}
 
+	var persona Persona
+
 	err = dbmap.SelectOne(&persona,
 		`SELECT
 			personas.cedula AS Cedula,
@@ -90,17 +242,33 @@ func GetPersona(w http.ResponseWriter, r *http.Request) error {
 		FROM
 			(SELECT * FROM personas WHERE cedula=?) AS personas
 		JOIN
-			padron ON padron.persona_id = personas.cedula,
-			juntas ON juntas.id = padron.junta_id,
-			centros ON centros.id = juntas.centro_id,
+			padron ON padron.persona_id = personas.cedula AND padron.eleccion_id = ?,
+			juntas ON juntas.eleccion_id = padron.eleccion_id AND juntas.id = padron.junta_id,
+			centros ON centros.eleccion_id = juntas.eleccion_id AND centros.id = juntas.centro_id,
 			distritos_electorales ON distritos_electorales.id = centros.distrito_electoral_id,
 			distritos ON distritos.id = distritos_electorales.distrito_id,
 			cantones ON cantones.id = distritos.canton_id,
 			provincias ON provincias.id = cantones.provincia_id`,
-		id)
+		cedula, e.Id)
+
+	if err != nil {
+		return nil, notFound{fmt.Errorf("persona no encontrada: %s", err)}
+	}
+	persona.Eleccion = e.Nombre
+
+	return &persona, nil
+}
+
+func GetPersona(w http.ResponseWriter, r *http.Request) error {
+	id, err := parseID(r)
+	log.Println("Id para persona ", id)
+	if err != nil {
+		return badRequest{err}
+	}
 
+	persona, err := lookupPersona(id, r.URL.Query().Get("eleccion"))
 	if err != nil {
-		return notFound{err}
+		return err
 	}
 
 	return json.NewEncoder(w).Encode(persona)
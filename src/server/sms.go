@@ -0,0 +1,163 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// smsMaxLen is the length of a single GSM-7 encoded SMS.
+const smsMaxLen = 160
+
+// SMSAdapter translates between an SMS gateway's webhook format and
+// the sender and text of a message.
+type SMSAdapter interface {
+	// Verify reports whether r comes from the gateway, which
+	// shares secret with the server or signs its requests with it.
+	Verify(r *http.Request, secret string) bool
+
+	// Parse extracts the sender and text of the incoming message.
+	Parse(r *http.Request) (from, body string, err error)
+
+	// Reply sends text back to the sender.
+	Reply(w http.ResponseWriter, to, text string) error
+}
+
+// genericSMS handles gateways that POST the message form-encoded as
+// "from" and "body", with the shared secret as "Authorization: Bearer
+// <secret>", and send the response body back as the reply.
+type genericSMS struct{}
+
+func (genericSMS) Verify(r *http.Request, secret string) bool {
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(auth), []byte(secret)) == 1
+}
+
+func (genericSMS) Parse(r *http.Request) (string, string, error) {
+	if err := r.ParseForm(); err != nil {
+		return "", "", err
+	}
+	return r.PostForm.Get("from"), r.PostForm.Get("body"), nil
+}
+
+func (genericSMS) Reply(w http.ResponseWriter, to, text string) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err := fmt.Fprint(w, text)
+	return err
+}
+
+var smsAdapters = map[string]SMSAdapter{
+	"generic": genericSMS{},
+}
+
+// RegisterSMSAdapter makes an SMS gateway adapter available under name.
+func RegisterSMSAdapter(name string, a SMSAdapter) {
+	smsAdapters[name] = a
+}
+
+// smsCedulaRe finds the cedula in a message, no longer than any valid
+// one written with separators, since it may be echoed in the reply.
+var smsCedulaRe = regexp.MustCompile(`\d[\d -]{0,18}\d`)
+
+// smsHandler answers the messages a relays from its gateway, which
+// must share secret with the server.
+func smsHandler(a SMSAdapter, secret string) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if !a.Verify(r, secret) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return nil
+		}
+
+		from, body, err := a.Parse(r)
+		if err != nil {
+			return badRequest{err}
+		}
+
+		if err := a.Reply(w, from, smsCut(gsm7(smsAnswer(from, body)))); err != nil {
+			log.Printf("W: Can't reply to SMS from %s: %s", from, err)
+		}
+		return nil
+	}
+}
+
+// smsAnswer returns the reply for an SMS with text body sent by from.
+func smsAnswer(from, body string) string {
+	if !limiter.Allow("sms:" + from) {
+		return "Demasiadas consultas, intente mas tarde."
+	}
+
+	cedula := smsCedulaRe.FindString(body)
+	if cedula == "" {
+		return "Envie su numero de cedula, por ejemplo 123456789."
+	}
+
+	persona, err := lookupPersona(cedula, "")
+	switch err.(type) {
+	case nil:
+		return formatPersonaSMS(persona)
+	case badRequest:
+		return "Cedula invalida: " + cedula +
+			". Envie los nueve digitos, por ejemplo 123456789."
+	case notFound:
+		return "No se encontraron datos para la cedula " +
+			NormalizeCedula(cedula) + "."
+	default:
+		log.Println(err)
+		return "No pudimos hacer la consulta, intente mas tarde."
+	}
+}
+
+// smsCut cuts s to a single SMS.
+func smsCut(s string) string {
+	if r := []rune(s); len(r) > smsMaxLen {
+		return string(r[:smsMaxLen])
+	}
+	return s
+}
+
+// formatPersonaSMS summarizes p in a single SMS, shortening the centro
+// name if needed.
+func formatPersonaSMS(p *Persona) string {
+	head := fmt.Sprintf("Junta %s. ", p.Mesa)
+	tail := fmt.Sprintf(", %s.", p.Distrito)
+
+	centro := []rune(p.Centro)
+	if room := smsMaxLen - len([]rune(head+tail)); len(centro) > room {
+		if room < 1 {
+			room = 1
+		}
+		centro = append(centro[:room-1], '.')
+	}
+
+	return smsCut(head + string(centro) + tail)
+}
+
+// gsm7Replacements maps the letters used in Spanish that are not part
+// of the GSM 03.38 basic character set to their closest equivalent.
+// é, É, ñ, Ñ, ü, Ü, ¿ and ¡ are in the set and are left alone.
+var gsm7Replacements = strings.NewReplacer(
+	"á", "a", "í", "i", "ó", "o", "ú", "u",
+	"Á", "A", "Í", "I", "Ó", "O", "Ú", "U",
+	"ê", "e", "Ê", "E", "ç", "c",
+	"“", "\"", "”", "\"", "‘", "'", "’", "'",
+	"–", "-", "—", "-",
+)
+
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./" +
+	"0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿" +
+	"abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7 strips accents and replaces any other character that can't be
+// sent using the GSM-7 basic character set, so that a reply never
+// falls back to UCS-2 and its 70 character limit.
+func gsm7(s string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(gsm7Basic, r) {
+			return r
+		}
+		return '?'
+	}, gsm7Replacements.Replace(s))
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const telegramAPI = "https://api.telegram.org"
+
+// TelegramBot answers messages sent to a bot using the Telegram Bot
+// API (https://core.telegram.org/bots/api).  Telegram POSTs each
+// update to the webhook and the bot replies calling sendMessage.
+type TelegramBot struct {
+	Token string
+
+	// APIURL is the base URL of the Bot API server, it can point
+	// to a local server for testing.
+	APIURL string
+
+	// Secret, if set, must match the
+	// X-Telegram-Bot-Api-Secret-Token header of each update.
+	Secret string
+
+	Client *http.Client
+}
+
+func NewTelegramBot(token, apiURL string) *TelegramBot {
+	if apiURL == "" {
+		apiURL = telegramAPI
+	}
+	return &TelegramBot{
+		Token:  token,
+		APIURL: strings.TrimRight(apiURL, "/"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type telegramUpdate struct {
+	UpdateId int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	MessageId int64 `json:"message_id"`
+	Chat      struct {
+		Id int64 `json:"id"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+func (b *TelegramBot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if b.Secret != "" && r.Header.Get("X-Telegram-Bot-Api-Secret-Token") != b.Secret {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var u telegramUpdate
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Anything other than a text message (edits, stickers, ...)
+	// is acknowledged and ignored, otherwise Telegram keeps
+	// retrying it.
+	if u.Message == nil || u.Message.Text == "" {
+		return
+	}
+
+	reply := b.answer(u.Message)
+
+	if err := b.sendMessage(u.Message.Chat.Id, reply); err != nil {
+		log.Printf("W: Can't reply to update %d: %s", u.UpdateId, err)
+	}
+}
+
+// answer returns the text to send back for message m.
+func (b *TelegramBot) answer(m *telegramMessage) string {
+	text := strings.TrimSpace(m.Text)
+
+	if strings.HasPrefix(text, "/") {
+		// /start, /help and any other command
+		return "Envíe su número de cédula (por ejemplo 123456789) " +
+			"y le diré dónde votar."
+	}
+
+	if !limiter.Allow("telegram:" + strconv.FormatInt(m.Chat.Id, 10)) {
+		return "Demasiadas consultas, intente más tarde."
+	}
+
+	persona, err := lookupPersona(text, "")
+	switch err.(type) {
+	case nil:
+		return FormatPersona(persona)
+	case badRequest:
+		return "Eso no parece un número de cédula. " +
+			"Envíe los nueve dígitos, por ejemplo 123456789."
+	case notFound:
+		return "No se encontraron datos para la cédula " +
+			NormalizeCedula(text) + "."
+	default:
+		log.Println(err)
+		return "Lo sentimos, no pudimos hacer la consulta. " +
+			"Intente más tarde."
+	}
+}
+
+// FormatPersona describes where p votes, in a few lines of text.
+func FormatPersona(p *Persona) string {
+	mapa := p.Url
+	if mapa == "" {
+		q := strings.Join([]string{p.Centro, p.Distrito, p.Canton,
+			p.Provincia, "Costa Rica"}, ", ")
+		mapa = "https://www.openstreetmap.org/search?query=" +
+			url.QueryEscape(q)
+	}
+
+	return fmt.Sprintf("%s %s %s\n"+
+		"Junta: %s\n"+
+		"Centro: %s\n"+
+		"Dirección: %s, %s, %s, %s\n"+
+		"Mapa: %s",
+		p.Nombre, p.Apellido1, p.Apellido2,
+		p.Mesa,
+		p.Centro,
+		p.Direccion, p.Distrito, p.Canton, p.Provincia,
+		mapa)
+}
+
+func (b *TelegramBot) sendMessage(chat int64, text string) error {
+	body, err := json.Marshal(struct {
+		ChatId int64  `json:"chat_id"`
+		Text   string `json:"text"`
+	}{chat, text})
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/bot%s/sendMessage", b.APIURL, b.Token)
+	r, err := b.Client.Post(u, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// Don't leak the token in the URL to the logs
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return fmt.Errorf("sendMessage: %s", err)
+	}
+	defer r.Body.Close()
+
+	var result struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		return err
+	}
+
+	if !result.Ok {
+		return fmt.Errorf("sendMessage: %s", result.Description)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"model"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const testRows = `
+	INSERT INTO provincias VALUES (1, 'SAN JOSE');
+	INSERT INTO cantones VALUES (101, 1, 'CENTRAL');
+	INSERT INTO distritos VALUES (101001, 101, 'CARMEN');
+	INSERT INTO distritos_electorales VALUES (101001001, 101001, 'CARMEN');
+	INSERT INTO elecciones (id, nombre, fecha) VALUES (1, '2018', '2018-02-04');
+	INSERT INTO centros VALUES (1, 7, 101001001, 'ESCUELA',
+		'REPUBLICA DE MEXICO', 'AVENIDA 7', '');
+	INSERT INTO juntas VALUES (1, 1, 7, 1);
+	INSERT INTO personas VALUES (101110111, '101110111', 20251231,
+		'JUAN', 'RODRIGUEZ', 'MORA', 1);
+	INSERT INTO padron VALUES (1, 101110111, 1)`
+
+// openTestDatabase serves a database with a single persona, in a
+// directory the caller must remove.
+func openTestDatabase(t *testing.T) string {
+	tmp, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tmp, "padron.db")
+
+	db, err := model.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = model.Migrate(db); err == nil {
+		_, err = db.Exec(testRows)
+	}
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := openDatabase(path); err != nil {
+		t.Fatal(err)
+	}
+	return tmp
+}
+
+type sentMessage struct {
+	ChatId int64  `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// fakeBotAPI records the messages sent through the Bot API.
+type fakeBotAPI struct {
+	sync.Mutex
+	paths []string
+	sent  []sentMessage
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var m sentMessage
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.Lock()
+	f.paths = append(f.paths, r.URL.Path)
+	f.sent = append(f.sent, m)
+	f.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"ok":true}`)
+}
+
+// take returns the messages sent since the last call.
+func (f *fakeBotAPI) take() ([]string, []sentMessage) {
+	f.Lock()
+	defer f.Unlock()
+	paths, sent := f.paths, f.sent
+	f.paths, f.sent = nil, nil
+	return paths, sent
+}
+
+// postUpdate POSTs a text message from chat to bot, with secret, and
+// returns the status of the response.
+func postUpdate(bot *TelegramBot, secret string, chat int64, text string) int {
+	body := fmt.Sprintf(`{"update_id":1,"message":{"message_id":1,"chat":{"id":%d},"text":%q}}`,
+		chat, text)
+	r := httptest.NewRequest("POST", "/telegram", strings.NewReader(body))
+	r.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+	w := httptest.NewRecorder()
+	bot.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestTelegramBot(t *testing.T) {
+	tmp := openTestDatabase(t)
+	defer os.RemoveAll(tmp)
+
+	api := &fakeBotAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	bot := NewTelegramBot("123:abc", srv.URL)
+	bot.Secret = "s3cr3t"
+
+	// One lookup per chat and minute
+	limiter = newRateLimiter(1, time.Minute)
+	defer func() { limiter = nil }()
+
+	tests := []struct {
+		secret string
+		chat   int64
+		text   string
+		status int
+		reply  string // contained in the reply, none if empty
+	}{
+		{"otro", 10, "101110111", http.StatusForbidden, ""},
+		{"s3cr3t", 10, "/start", http.StatusOK, "Envíe su número de cédula"},
+		{"s3cr3t", 10, "1-0111-0111", http.StatusOK,
+			"JUAN RODRIGUEZ MORA\nJunta: 1\nCentro: REPUBLICA DE MEXICO\n" +
+				"Dirección: AVENIDA 7, CARMEN, CENTRAL, SAN JOSE\n"},
+		{"s3cr3t", 10, "101110111", http.StatusOK,
+			"Demasiadas consultas, intente más tarde."},
+		{"s3cr3t", 20, "999999999", http.StatusOK,
+			"No se encontraron datos para la cédula 999999999."},
+		{"s3cr3t", 30, "hola", http.StatusOK, "Eso no parece un número de cédula."},
+	}
+
+	for _, tt := range tests {
+		status := postUpdate(bot, tt.secret, tt.chat, tt.text)
+		if status != tt.status {
+			t.Errorf("%q from %d: status %d, want %d", tt.text, tt.chat, status, tt.status)
+		}
+
+		paths, sent := api.take()
+		if tt.reply == "" {
+			if len(sent) != 0 {
+				t.Errorf("%q from %d: sent %v, want nothing", tt.text, tt.chat, sent)
+			}
+			continue
+		}
+		if len(sent) != 1 {
+			t.Errorf("%q from %d: sent %v, want a message", tt.text, tt.chat, sent)
+			continue
+		}
+		if paths[0] != "/bot123:abc/sendMessage" {
+			t.Errorf("%q from %d: called %s", tt.text, tt.chat, paths[0])
+		}
+		if sent[0].ChatId != tt.chat || !strings.Contains(sent[0].Text, tt.reply) {
+			t.Errorf("%q from %d: sent %q to %d, want %q", tt.text, tt.chat,
+				sent[0].Text, sent[0].ChatId, tt.reply)
+		}
+	}
+}
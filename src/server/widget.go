@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+const (
+	widgetWidth  = 320
+	widgetHeight = 360
+)
+
+// widgetTheme holds the presentation options a partner can set through
+// the widget's query parameters.
+type widgetTheme struct {
+	Name   string // "claro" or "oscuro"
+	Accent string // CSS color, e.g. "#337ab7"
+}
+
+var colorRe = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+func parseTheme(q url.Values) widgetTheme {
+	t := widgetTheme{Name: "claro", Accent: "#337ab7"}
+
+	switch q.Get("tema") {
+	case "oscuro", "dark":
+		t.Name = "oscuro"
+	}
+
+	if c := q.Get("color"); colorRe.MatchString(c) {
+		t.Accent = c
+	}
+
+	return t
+}
+
+// params returns the query parameters needed to reproduce the theme.
+func (t widgetTheme) params() url.Values {
+	v := url.Values{}
+	v.Set("tema", t.Name)
+	v.Set("color", t.Accent)
+	return v
+}
+
+var widgetTmpl = template.Must(template.New("widget").Parse(`<!doctype html>
+<html lang="es">
+<head>
+  <meta charset="utf-8">
+  <meta name=viewport content="width=device-width, initial-scale=1">
+  <title>¿Dónde voto?</title>
+  <link rel="alternate" type="application/json+oembed" href="{{.OEmbed}}" title="¿Dónde voto?">
+  <style>
+    body { margin: 0; padding: 8px; font-family: sans-serif; font-size: 14px;
+      {{if eq .Theme.Name "oscuro"}}background: #222; color: #eee;{{else}}background: #fff; color: #333;{{end}} }
+    h1 { font-size: 16px; margin: 0 0 8px 0; color: {{.Theme.Accent}}; }
+    form { display: flex; margin-bottom: 8px; }
+    input[type=search] { flex: 1; padding: 4px; font-size: 14px; }
+    button { margin-left: 4px; padding: 4px 8px; border: 0; color: #fff;
+      background: {{.Theme.Accent}}; font-size: 14px; }
+    .card { border: 1px solid {{.Theme.Accent}}; padding: 8px; }
+    .card dt { font-weight: bold; }
+    .card dd { margin: 0 0 6px 0; }
+    a { color: {{.Theme.Accent}}; }
+  </style>
+</head>
+<body>
+  <h1>¿Dónde voto?</h1>
+  <form method="GET" action="/widget">
+    <input type="search" name="cedula" value="{{.Cedula}}" placeholder="123456789" required>
+    {{range $k, $v := .ThemeParams}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">
+    {{end}}<button type="submit">Buscar</button>
+  </form>
+  {{with .Persona}}
+  <div class="card">
+    <dl>
+      <dt>{{.Nombre}} {{.Apellido1}} {{.Apellido2}}</dt>
+      <dd>Cédula {{.Cedula}}</dd>
+      <dt>Mesa de votación</dt>
+      <dd>{{.Mesa}}</dd>
+      <dt>Centro de votación</dt>
+      <dd>{{if .Url}}<a href="{{.Url}}" target="_blank">{{.Centro}}</a>{{else}}{{.Centro}}{{end}}</dd>
+      <dt>Dirección</dt>
+      <dd>{{.Direccion}}<br>{{.Distrito}}, {{.Canton}}, {{.Provincia}}</dd>
+    </dl>
+  </div>
+  {{else}}{{if .Cedula}}
+  <div class="card">No se encontraron datos.</div>
+  {{end}}{{end}}
+</body>
+</html>
+`))
+
+// baseURL returns the scheme and host the request was addressed to.
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func GetWidget(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	theme := parseTheme(q)
+	cedula := q.Get("cedula")
+
+	oembed := url.Values{}
+	oembed.Set("url", baseURL(r)+"/widget?"+theme.params().Encode())
+	oembed.Set("format", "json")
+
+	data := struct {
+		Cedula      string
+		Persona     *Persona
+		Theme       widgetTheme
+		ThemeParams url.Values
+		OEmbed      string
+	}{
+		Cedula:      cedula,
+		Theme:       theme,
+		ThemeParams: theme.params(),
+		OEmbed:      baseURL(r) + "/oembed?" + oembed.Encode(),
+	}
+
+	if cedula != "" {
+		persona, err := lookupPersona(cedula, q.Get("eleccion"))
+		switch err.(type) {
+		case nil:
+			data.Persona = persona
+		case notFound, badRequest:
+			// The template reports it
+		default:
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return widgetTmpl.Execute(w, data)
+}
+
+// GetOEmbed describes the widget following the oEmbed specification
+// (https://oembed.com/) so that partner CMSs can embed it from a URL.
+func GetOEmbed(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+
+	if f := q.Get("format"); f != "" && f != "json" {
+		http.Error(w, "formato no soportado: "+f, http.StatusNotImplemented)
+		return nil
+	}
+
+	u, err := url.Parse(q.Get("url"))
+	if err != nil || q.Get("url") == "" {
+		return badRequest{fmt.Errorf("url inválido: %q", q.Get("url"))}
+	}
+
+	theme := parseTheme(u.Query())
+
+	width, height := widgetWidth, widgetHeight
+	if mw, err := strconv.Atoi(q.Get("maxwidth")); err == nil && mw > 0 && mw < width {
+		width = mw
+	}
+	if mh, err := strconv.Atoi(q.Get("maxheight")); err == nil && mh > 0 && mh < height {
+		height = mh
+	}
+
+	src := baseURL(r) + "/widget?" + theme.params().Encode()
+
+	resp := struct {
+		Version      string `json:"version"`
+		Type         string `json:"type"`
+		Title        string `json:"title"`
+		ProviderName string `json:"provider_name"`
+		ProviderURL  string `json:"provider_url"`
+		Html         string `json:"html"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+	}{
+		Version:      "1.0",
+		Type:         "rich",
+		Title:        "¿Dónde voto?",
+		ProviderName: "#VotoCR",
+		ProviderURL:  baseURL(r) + "/",
+		Html: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" title="¿Dónde voto?"></iframe>`,
+			template.HTMLEscapeString(src), width, height),
+		Width:  width,
+		Height: height,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,144 @@
+package tse
+
+import (
+	"cli"
+	"database/sql"
+	"log"
+	"model"
+
+	"github.com/coopernurse/gorp"
+)
+
+// Check compares the geography of the centros with DondeVotar.
+var Check = &cli.Command{
+	Name:  "check",
+	Short: "compare the provincia, canton and distrito of the centros with the TSE",
+	Long: `
+Asks the DondeVotar service of the TSE about a persona of each centro
+of the latest election, and logs the centros whose provincia, canton or
+distrito electoral it names otherwise.
+`,
+}
+
+var checkUrl = Check.Flag.String("url", DondeVotar,
+	"DondeVotar service to ask, e.g. the one of bin/generate -serve")
+
+func init() {
+	Check.Run = runCheck
+}
+
+type checkInfo struct {
+	EleccionId int64
+	CentroId   int64
+	Cedula     string
+}
+
+func checkCentro(dbmap *gorp.DbMap, d checkInfo) {
+	log.Printf("Start processing %v\n", d)
+
+	l, err := dondeVotar(*checkUrl, d.Cedula)
+	if err != nil {
+		log.Printf("W: %v: %s", d, err)
+		return
+	}
+
+	var centro struct {
+		Id        int64  `db:"id"`
+		Tipo      string `db:"tipo"`
+		Nombre    string `db:"nombre"`
+		Direccion string `db:"direccion"`
+		Url       string `db:"url"`
+		Distrito  string `db:"distrito"`
+		Canton    string `db:"canton"`
+		Provincia string `db:"provincia"`
+	}
+
+	trans, err := dbmap.Begin()
+	if err != nil {
+		log.Printf("W: Can't start transaction: %s", err)
+		return
+	}
+
+	err = trans.SelectOne(&centro,
+		`SELECT
+			centros.id AS id,
+			centros.tipo AS tipo,
+			centros.nombre AS nombre,
+			centros.direccion AS direccion,
+			centros.url AS url,
+			distritos_electorales.nombre AS distrito,
+			cantones.nombre AS canton,
+			provincias.nombre AS provincia
+		FROM centros
+		JOIN
+			distritos_electorales ON distritos_electorales.id = centros.distrito_electoral_id,
+			distritos ON distritos.id = distritos_electorales.distrito_id,
+			cantones ON cantones.id = distritos.canton_id,
+			provincias ON provincias.id = cantones.provincia_id
+		WHERE centros.eleccion_id=? AND centros.id=?`,
+		d.EleccionId, d.CentroId)
+	switch err {
+	case nil:
+		// ok
+	case sql.ErrNoRows:
+	default:
+		log.Printf("W: Can't get data for %v: %s", centro, err)
+		trans.Rollback()
+		return
+	}
+	trans.Commit()
+
+	if centro.Provincia != l.DescripcionProvincia {
+		log.Println(centro.Id, centro.Provincia, l.DescripcionProvincia)
+	}
+
+	if centro.Canton != l.DescripcionCanton {
+		log.Println(centro.Id, centro.Canton, l.DescripcionCanton)
+	}
+
+	if centro.Distrito != l.DescripcionDistrito {
+		log.Println(centro.Id, centro.Distrito, l.DescripcionDistrito)
+	}
+}
+
+func runCheck(cmd *cli.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Fail()
+	}
+
+	dbmap := cli.OpenDb()
+	defer dbmap.Db.Close()
+
+	// The TSE only answers for the current election
+	e, err := model.FindEleccion(dbmap, "")
+	if err != nil {
+		log.Fatalf(`E: Can't find the latest election: %s. Abort.`, err)
+	}
+
+	var data []checkInfo
+
+	_, err = dbmap.Select(
+		&data,
+		`SELECT
+			centros.eleccion_id AS EleccionId,
+			centros.id AS CentroId,
+			personas.cedula AS Cedula
+		FROM
+			padron
+		JOIN
+			personas ON personas.cedula = padron.persona_id,
+			juntas ON juntas.eleccion_id = padron.eleccion_id AND juntas.id = padron.junta_id,
+			centros ON centros.eleccion_id = juntas.eleccion_id AND centros.id = juntas.centro_id
+		WHERE padron.eleccion_id = ?
+		GROUP BY centros.id
+		ORDER BY RANDOM()
+		`, e.Id)
+
+	if err != nil {
+		log.Fatalf(`E: Can't query padron: %s. Abort.`, err)
+	}
+
+	each(len(data), func(i int) {
+		checkCentro(dbmap, data[i])
+	})
+}
@@ -0,0 +1,95 @@
+// Package tse asks the DondeVotar service of the TSE where personas
+// vote, to complete and check the centros of the database.
+package tse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DondeVotar is the service of the TSE that tells where a cedula votes.
+const DondeVotar = "http://www.consulta.tse.go.cr/DondeVotarM/prRemoto.aspx/ObtenerDondeVotar"
+
+// lista is the answer of DondeVotar.
+type lista struct {
+	CodElectoral         int
+	DireccionEscuela     string
+	NombreCentroVotacion string
+	Url                  string
+	DescripcionProvincia string
+	DescripcionCanton    string
+	DescripcionDistrito  string
+}
+
+var errCrying = errors.New("oops, we made the server cry")
+
+// dondeVotar asks the service at url where cedula votes.
+func dondeVotar(url, cedula string) (*lista, error) {
+	var result struct {
+		D struct {
+			Lista lista
+		}
+	}
+
+	query := fmt.Sprintf(`{"numeroCedula":"%s"}`, cedula)
+
+	for retries := 5; retries > 0; retries-- {
+		r, err := http.Post(url, "application/json; charset=UTF-8",
+			strings.NewReader(query))
+		if err != nil {
+			return nil, fmt.Errorf("can't query data: %s", err)
+		}
+
+		resp, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("can't read data: %s", err)
+		}
+
+		err = json.Unmarshal(resp, &result)
+		if err != nil {
+			return nil, fmt.Errorf("can't get data: %s", err)
+		}
+
+		if result.D.Lista.CodElectoral != 0 {
+			return &result.D.Lista, nil
+		}
+
+		// We are killing the server, and it won't admit
+		// it.  Let's be gentle and let it take a breath
+		time.Sleep(1 * time.Second)
+	}
+
+	return nil, errCrying
+}
+
+// each calls f with 0 to n-1, two at a time not to overwhelm the
+// server, and waits for them.
+func each(n int, f func(i int)) {
+	ch := make(chan int, 2)
+
+	for i := 0; i < cap(ch); i++ {
+		ch <- 1
+	}
+
+	pending := cap(ch)
+
+	for i := 0; i < n; i++ {
+		<-ch
+		pending--
+		go func(i int) {
+			f(i)
+			ch <- 1
+		}(i)
+		pending++
+	}
+
+	for ; pending > 0; pending-- {
+		<-ch
+	}
+}
@@ -0,0 +1,107 @@
+package tse
+
+import (
+	"cli"
+	"database/sql"
+	"log"
+	"model"
+)
+
+// Scrape fills in the direccion and map of the centros from DondeVotar.
+var Scrape = &cli.Command{
+	Name:  "scrape",
+	Short: "add the direccion and map of the centros from the TSE",
+	Long: `
+Asks the DondeVotar service of the TSE about a persona of each centro
+of the latest election without a map, and saves its direccion and map.
+`,
+}
+
+var scrapeUrl = Scrape.Flag.String("url", DondeVotar,
+	"DondeVotar service to ask, e.g. the one of bin/generate -serve")
+
+func init() {
+	Scrape.Run = runScrape
+}
+
+func runScrape(cmd *cli.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Fail()
+	}
+
+	dbmap := cli.OpenDb()
+	defer dbmap.Db.Close()
+
+	// The TSE only answers for the current election
+	e, err := model.FindEleccion(dbmap, "")
+	if err != nil {
+		log.Fatalf(`E: Can't find the latest election: %s. Abort.`, err)
+	}
+
+	type ScrapeInfo struct {
+		CentroId int64
+		Cedula   string
+	}
+
+	var data []ScrapeInfo
+
+	_, err = dbmap.Select(
+		&data,
+		`SELECT
+			centros.id AS CentroId,
+			personas.cedula AS Cedula
+		FROM
+			padron
+		JOIN
+			personas ON personas.cedula = padron.persona_id,
+			juntas ON juntas.eleccion_id = padron.eleccion_id AND juntas.id = padron.junta_id,
+			centros ON centros.eleccion_id = juntas.eleccion_id AND centros.id = juntas.centro_id
+		WHERE padron.eleccion_id = ? AND centros.url = ''
+		GROUP BY centros.id`, e.Id)
+
+	if err != nil {
+		log.Fatalf(`E: Can't query padron: %s. Abort.`, err)
+	}
+
+	each(len(data), func(i int) {
+		d := data[i]
+		log.Printf("Start processing %v\n", d)
+
+		l, err := dondeVotar(*scrapeUrl, d.Cedula)
+		if err != nil {
+			log.Printf("W: %v: %s", d, err)
+			return
+		}
+
+		var centro model.Centro
+
+		trans, err := dbmap.Begin()
+		if err != nil {
+			log.Printf("W: Can't start transaction: %s", err)
+			return
+		}
+
+		err = trans.SelectOne(&centro, `SELECT * FROM centros WHERE eleccion_id=? AND id=?`,
+			e.Id, d.CentroId)
+
+		switch err {
+		case nil:
+			// ok
+		case sql.ErrNoRows:
+			err = trans.Insert(&centro)
+		default:
+			log.Printf("W: Can't get data for %v: %s", centro, err)
+			trans.Rollback()
+			return
+		}
+
+		centro.Direccion = l.DireccionEscuela
+		centro.Url = l.Url
+
+		log.Printf("Centro: %#v\n", centro)
+
+		trans.Update(&centro)
+
+		trans.Commit()
+	})
+}
@@ -57,4 +57,11 @@ padronApp.controller('PadronCtrl', function ($scope, $http) {
       $scope.found = 0;
     });
   };
+
+  // Links printed on the slips carry the cedula to look up
+  var m = /[?&]cedula=([^&#]*)/.exec(window.location.search);
+  if (m) {
+    $scope.cedula = decodeURIComponent(m[1]);
+    $scope.search();
+  }
 });